@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"main/internal/media"
+	"main/internal/migrations"
+	"main/internal/pg"
+)
+
+const usage = `usage:
+  server                        start the HTTP server
+  server migrate up             apply all pending migrations
+  server migrate down [n]       roll back the last n migrations (default 1)
+  server migrate status         list migrations and whether they are applied
+  server migrate force VERSION  mark migrations up to VERSION as applied without running them
+  server seed                   insert development seed data
+  server media cleanup          delete uploads that were never attached to a post`
+
+// runCommand executes a CLI subcommand instead of starting the server
+func runCommand(args []string) error {
+	ctx := context.Background()
+
+	switch args[0] {
+	case "migrate":
+		if len(args) < 2 {
+			return fmt.Errorf("missing migrate subcommand\n%s", usage)
+		}
+		return runMigrate(ctx, args[1:])
+	case "media":
+		if len(args) < 2 || args[1] != "cleanup" {
+			return fmt.Errorf("unknown media subcommand\n%s", usage)
+		}
+		removed, err := media.CleanupOrphans(ctx, orphanMediaGrace)
+		if err != nil {
+			return err
+		}
+		zap.S().Infof("Removed %d orphan media files", removed)
+		return nil
+	case "seed":
+		if err := migrations.Seed(ctx, pg.DB); err != nil {
+			return err
+		}
+		zap.S().Info("Seed data inserted")
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func runMigrate(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, pg.DB)
+		for _, m := range applied {
+			zap.S().Infof("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			zap.S().Info("Database schema is up to date")
+		}
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := migrations.Down(ctx, pg.DB, steps)
+		for _, m := range rolledBack {
+			zap.S().Infof("Rolled back migration %04d_%s", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := migrations.GetStatus(ctx, pg.DB)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	case "force":
+		if len(args) < 2 {
+			return fmt.Errorf("missing version\n%s", usage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := migrations.Force(ctx, pg.DB, version); err != nil {
+			return err
+		}
+		zap.S().Infof("Marked migrations up to %04d as applied", version)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q\n%s", args[0], usage)
+	}
+}
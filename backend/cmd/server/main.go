@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"os"
 	"time"
@@ -15,12 +17,24 @@ import (
 	"go.uber.org/zap"
 
 	"main/internal/auth/users"
+	"main/internal/cache"
 	"main/internal/comments"
+	"main/internal/community/communities"
 	community "main/internal/community/posts"
+	"main/internal/community/roles"
+	"main/internal/community/subscriptions"
+	"main/internal/feed"
+	"main/internal/media"
 	"main/internal/middleware"
+	"main/internal/models"
 	"main/internal/pg"
+	"main/internal/profile/avatar"
+	"main/internal/profile/blocks"
+	"main/internal/profile/follows"
 	"main/internal/profile/friends"
+	"main/internal/profile/graph"
 	profile "main/internal/profile/posts"
+	"main/internal/storage"
 )
 
 var (
@@ -28,6 +42,36 @@ var (
 	redisPool      *redis.Pool
 )
 
+// Неприкреплённые к постам загрузки живут не дольше orphanMediaGrace
+const (
+	orphanMediaInterval = time.Hour
+	orphanMediaGrace    = 24 * time.Hour
+)
+
+// newStorage picks the file storage for avatars and media:
+// STORAGE_BACKEND=local (UPLOAD_DIR, по умолчанию ./uploads) или
+// STORAGE_BACKEND=s3 (S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY, S3_SECRET_KEY)
+func newStorage() (storage.Storage, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("UPLOAD_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return storage.NewLocal(dir)
+	case "s3":
+		return storage.NewS3(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_ACCESS_KEY"),
+			os.Getenv("S3_SECRET_KEY"),
+		)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
 func initLogger() {
 	logger, _ := zap.NewDevelopment()
 	zap.ReplaceGlobals(logger)
@@ -56,6 +100,7 @@ func main() {
 	zap.S().Info("Successfully configured Redis connection pool for Dragonfly")
 
 	sessionManager.Store = redisstore.New(redisPool)
+	cache.Pool = redisPool
 	sessionManager.Lifetime = 24 * time.Hour
 	sessionManager.Cookie.Name = "session_id"
 	sessionManager.Cookie.HttpOnly = true
@@ -80,6 +125,22 @@ func main() {
 	}
 	zap.S().Info("Database is ready to accept connections")
 
+	store, err := newStorage()
+	if err != nil {
+		zap.S().Fatalf("Failed to prepare upload storage: %v", err)
+	}
+	avatar.Store = store
+	media.Store = store
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			zap.S().Fatal(err)
+		}
+		return
+	}
+
+	go media.RunOrphanCleanup(context.Background(), orphanMediaInterval, orphanMediaGrace)
+
 	r := gin.Default()
 
 	// CORS Middleware
@@ -105,72 +166,138 @@ func main() {
 		users.AuthorizeUser(c, sessionManager)
 	})
 
-	r.GET("/community", pg.InsertCommunityInDB)
-	r.GET("/community/:id/subscribers", pg.GetCommunitySubscribers)
-	r.GET("/community/:id", pg.GetCommunityByID)
+	optionalAuth := middleware.OptionalAuthMiddleware(sessionManager)
 
-	r.GET("/user/:userID/posts", profile.GetUserPosts)
-	r.GET("/user/posts/:postID", profile.GetPost)
+	r.GET("/community/:id/subscribers", optionalAuth, subscriptions.GetSubscribersHandler)
+	r.GET("/community/:id", optionalAuth, communities.GetCommunityHandler)
 
-	r.GET("/community/:id/posts", community.GetUserPosts)
-	r.GET("/community/:id/posts/:postID", community.GetPost)
+	// Комментарии к посту проверяются так же, как сам пост
+	scopeCommunityPost := community.ScopePost()
+	scopeWallPost := profile.ScopeWallPost()
+	r.GET("/user/:userID/posts", optionalAuth, profile.GetUserPosts)
+	r.GET("/user/posts/:postID", optionalAuth, profile.GetPost)
+	r.GET("/user/posts/:postID/reactions", optionalAuth, profile.GetReactions)
+	r.GET("/user/:userID", optionalAuth, pg.GetUserProfile)
+	r.GET("/user/:userID/avatar", avatar.GetAvatarHandler)
+
+	r.GET("/community/:id/posts", optionalAuth, community.GetCommunityPosts)
+	r.GET("/community/:id/posts/:postID", optionalAuth, community.GetPost)
+	r.GET("/community/:id/posts/:postID/reactions", optionalAuth, community.GetReactions)
 
 	r.GET("/graph-data", pg.GetGraphData)
+	r.GET("/media/:id", optionalAuth, media.GetHandler)
 
-	r.GET("/community/:id/posts/:postID/comments?limit=20&offset=0", comments.GetCommentsByPostID)
-	r.GET("/user/posts/:postID/comments?limit=20&offset=0", comments.GetCommentsByPostID)
-	r.GET("/community/:id/posts/:postID/comments/:commentID", comments.GetComment)
-	r.GET("user/posts/:postID/comments/:commentID", comments.GetComment)
+	r.GET("/community/:id/posts/:postID/comments", optionalAuth, scopeCommunityPost, comments.GetCommentsByPostID)
+	r.GET("/user/posts/:postID/comments", optionalAuth, scopeWallPost, comments.GetCommentsByPostID)
+	r.GET("/community/:id/posts/:postID/comments/:commentID", optionalAuth, scopeCommunityPost, comments.GetComment)
+	r.GET("/user/posts/:postID/comments/:commentID", optionalAuth, scopeWallPost, comments.GetComment)
+	r.GET("/community/:id/posts/:postID/comments/:commentID/replies", optionalAuth, scopeCommunityPost, comments.GetCommentReplies)
+	r.GET("/user/posts/:postID/comments/:commentID/replies", optionalAuth, scopeWallPost, comments.GetCommentReplies)
 
 	// Protected routes
 	api := r.Group("/api")
 	api.Use(middleware.AuthMiddleware(sessionManager))
+
+	requireWriter := middleware.RequireCommunityRole(models.RoleWriter)
+	requireAdmin := middleware.RequireCommunityRole(models.RoleAdmin)
 	{
-		api.GET("/user", pg.GetUserProfile)
+		api.GET("/me", pg.GetMyProfile)
+		api.GET("/user", pg.GetMyProfile)
 		api.PUT("/user", pg.UpdateProfile)
-		api.GET("/user/search?query=john", pg.SearchUsers)
+		api.PUT("/user/avatar", avatar.UploadAvatarHandler)
+		api.GET("/user/search", pg.SearchUsers)
 
-		api.POST("/community/:id/posts/:postID/comments", comments.CreateComment)
-		api.POST("/user/posts/:postID/comments", comments.CreateComment)
-		api.PUT("/community/:id/posts/:postID/comments/:commentID", comments.UpdateComment)
-		api.PUT("/user/posts/:postID/comments/:commentID", comments.UpdateComment)
-		api.DELETE("/community/:id/posts/:postID/comments/:commentID", comments.DeleteComment)
-		api.DELETE("/user/posts/:postID/comments/:commentID", comments.DeleteComment)
+		api.POST("/community/:id/posts/:postID/comments", scopeCommunityPost, comments.CreateComment)
+		api.POST("/user/posts/:postID/comments", scopeWallPost, comments.CreateComment)
+		api.PUT("/community/:id/posts/:postID/comments/:commentID", scopeCommunityPost, comments.UpdateComment)
+		api.PUT("/user/posts/:postID/comments/:commentID", scopeWallPost, comments.UpdateComment)
+		api.DELETE("/community/:id/posts/:postID/comments/:commentID", scopeCommunityPost, middleware.LoadCommunityRole(), comments.DeleteComment)
+		api.DELETE("/user/posts/:postID/comments/:commentID", scopeWallPost, comments.DeleteComment)
+		api.POST("/community/:id/posts/:postID/comments/:commentID/reactions", scopeCommunityPost, comments.AddReaction)
+		api.DELETE("/community/:id/posts/:postID/comments/:commentID/reactions", scopeCommunityPost, comments.RemoveReaction)
+		api.POST("/user/posts/:postID/comments/:commentID/reactions", scopeWallPost, comments.AddReaction)
+		api.DELETE("/user/posts/:postID/comments/:commentID/reactions", scopeWallPost, comments.RemoveReaction)
 
 		api.GET("/users", users.GetAllUsers)
 
+		api.GET("/feed", feed.GetFeed)
+
+		api.POST("/media", media.UploadHandler)
+
 		api.POST("/user/posts", profile.CreatePost)
 		api.PUT("/user/posts/:postID", profile.UpdatePost)
 		api.DELETE("/user/posts/:postID", profile.DeletePost)
 		api.POST("/user/posts/:postID/like", profile.LikePost)
-		api.DELETE("/user/posts/:postID/like", profile.UnlikePost)
+		api.DELETE("/user/posts/:postID/like", profile.RemoveReaction)
+		api.PUT("/user/posts/:postID/reaction", profile.SetReaction)
+		api.DELETE("/user/posts/:postID/reaction", profile.RemoveReaction)
 
-		api.POST("/community/:id/posts", community.CreatePost)
+		// Community management
+		api.POST("/community", communities.CreateCommunityHandler)
+		api.PUT("/community/:id", requireAdmin, communities.UpdateCommunityHandler)
+		api.DELETE("/community/:id", requireAdmin, communities.DeleteCommunityHandler)
+		api.GET("/communities/recommended", communities.RecommendedHandler)
+
+		api.GET("/community/:id/roles", roles.GetRolesHandler)
+
+		// Community role management (admins only)
+		api.PUT("/community/:id/admins/:user_id", requireAdmin, func(c *gin.Context) {
+			roles.GrantRoleHandler(c, models.RoleAdmin)
+		})
+		api.DELETE("/community/:id/admins/:user_id", requireAdmin, func(c *gin.Context) {
+			roles.RevokeRoleHandler(c, models.RoleAdmin)
+		})
+		api.PUT("/community/:id/writers/:user_id", requireAdmin, func(c *gin.Context) {
+			roles.GrantRoleHandler(c, models.RoleWriter)
+		})
+		api.DELETE("/community/:id/writers/:user_id", requireAdmin, func(c *gin.Context) {
+			roles.RevokeRoleHandler(c, models.RoleWriter)
+		})
+
+		// Community membership routes
+		api.POST("/community/:id/subscription", subscriptions.SubscribeHandler)
+		api.DELETE("/community/:id/subscription", subscriptions.UnsubscribeHandler)
+		api.GET("/me/communities", subscriptions.GetMyCommunitiesHandler)
+		api.GET("/community/:id/join-requests", requireAdmin, subscriptions.GetJoinRequestsHandler)
+		api.POST("/community/:id/join-requests/:request_id/approve", requireAdmin, subscriptions.ApproveJoinRequestHandler)
+		api.POST("/community/:id/join-requests/:request_id/reject", requireAdmin, subscriptions.RejectJoinRequestHandler)
+
+		api.POST("/community/:id/posts", requireWriter, community.CreatePost)
 		api.PUT("/community/:id/posts/:postID", community.UpdatePost)
-		api.DELETE("/community/:id/posts/:postID", community.DeletePost)
+		api.DELETE("/community/:id/posts/:postID", middleware.LoadCommunityRole(), community.DeletePost)
 		api.POST("/community/:id/posts/:postID/like", community.LikePost)
-		api.DELETE("/community/:id/posts/:postID/like", community.UnlikePost)
+		api.DELETE("/community/:id/posts/:postID/like", community.RemoveReaction)
+		api.PUT("/community/:id/posts/:postID/reaction", community.SetReaction)
+		api.DELETE("/community/:id/posts/:postID/reaction", community.RemoveReaction)
 
 		api.POST("/logout", func(c *gin.Context) {
 			users.LogoutUser(c, sessionManager)
 		})
 
 		// Friend routes
-		api.POST("/friends/requests", func(c *gin.Context) {
-			friends.SendFriendRequestHandler(c, sessionManager)
-		})
-		api.GET("/friends", func(c *gin.Context) {
-			friends.GetFriendsHandler(c, sessionManager)
-		})
-		api.DELETE("/friends/:friend_id", func(c *gin.Context) {
-			friends.DeleteFriendHandler(c, sessionManager)
-		})
-		api.GET("/friends/requests/incoming", func(c *gin.Context) {
-			friends.GetIncomingRequestsHandler(c, sessionManager)
-		})
-		api.PUT("/friends/requests/:request_id", func(c *gin.Context) {
-			friends.UpdateFriendRequestHandler(c, sessionManager)
-		})
+		api.POST("/friends/requests", friends.SendFriendRequestHandler)
+		api.GET("/friends", friends.GetFriendsHandler)
+		api.DELETE("/friends/:friend_id", friends.DeleteFriendHandler)
+		api.GET("/friends/requests/incoming", friends.GetIncomingRequestsHandler)
+		api.GET("/friends/requests/outgoing", friends.GetOutgoingRequestsHandler)
+		api.PUT("/friends/requests/:request_id", friends.UpdateFriendRequestHandler)
+		api.DELETE("/friends/requests/:request_id", friends.CancelFriendRequestHandler)
+		api.GET("/friends/suggestions", friends.GetSuggestionsHandler)
+		api.GET("/users/:id/mutual-friends", friends.GetMutualFriendsHandler)
+
+		api.POST("/users/:id/follow", follows.FollowHandler)
+		api.DELETE("/users/:id/follow", follows.UnfollowHandler)
+		api.GET("/users/:id/followers", follows.GetFollowersHandler)
+		api.GET("/users/:id/following", follows.GetFollowingHandler)
+
+		// Friend graph analytics
+		api.GET("/graph/friends", graph.GetFriendNetworkHandler)
+		api.GET("/graph/friends/path", graph.GetFriendPathHandler)
+
+		// Block routes
+		api.GET("/blocks", blocks.GetBlocksHandler)
+		api.POST("/blocks/:user_id", blocks.BlockUserHandler)
+		api.DELETE("/blocks/:user_id", blocks.UnblockUserHandler)
 	}
 
 	r.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,49 @@
+package identity
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// contextKey is the gin context key holding the *Principal
+const contextKey = "principal"
+
+// Principal is the authenticated user of the current request
+type Principal struct {
+	ID       int64
+	Username string
+	// Roles maps community ID to the user's highest role there
+	// (models.RoleAdmin, models.RoleWriter or models.RoleSubscriber)
+	Roles map[int64]string
+}
+
+// CommunityRole returns the principal's role in a community or an empty string
+func (p *Principal) CommunityRole(communityID int64) string {
+	if p == nil {
+		return ""
+	}
+	return p.Roles[communityID]
+}
+
+// Set stores the principal in the gin context
+func Set(c *gin.Context, p *Principal) {
+	c.Set(contextKey, p)
+}
+
+// Get returns the principal loaded by middleware.AuthMiddleware
+func Get(c *gin.Context) (*Principal, bool) {
+	v, exists := c.Get(contextKey)
+	if !exists {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok && p != nil
+}
+
+// UserID returns the authenticated user's ID or 0 for anonymous requests
+func UserID(c *gin.Context) int64 {
+	p, ok := Get(c)
+	if !ok {
+		return 0
+	}
+	return p.ID
+}
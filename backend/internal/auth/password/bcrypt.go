@@ -0,0 +1,47 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const DefaultBcryptCost = 12
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt returns a Hasher producing standard $2a$<cost>$... strings
+func NewBcrypt(cost int) Hasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) ID() string {
+	return "2a"
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return false, ErrMalformedHash
+	}
+	return true, nil
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.cost
+}
@@ -0,0 +1,26 @@
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// verifyLegacySHA256 checks hashes created before the Hasher interface:
+// hex(sha256(password || salt)) with the hex salt kept in users.salt.
+// Such hashes are always rehashed on successful login.
+func verifyLegacySHA256(password, hashHex, saltHex string) (bool, error) {
+	salt, err := hex.DecodeString(saltHex)
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	hash, err := hex.DecodeString(hashHex)
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+
+	data := append([]byte(password), salt...)
+	sum := sha256.Sum256(data)
+
+	return subtle.ConstantTimeCompare(sum[:], hash) == 1, nil
+}
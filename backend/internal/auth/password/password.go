@@ -2,9 +2,48 @@ package password
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
+	"errors"
+	"strings"
 )
 
+var (
+	ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+	ErrMalformedHash    = errors.New("malformed password hash")
+)
+
+// Hasher hashes passwords into self-describing strings and verifies them.
+// Encoded hashes carry the algorithm and its parameters, so the users table
+// does not need a separate salt column for modern hashes.
+type Hasher interface {
+	// ID is the algorithm identifier used in the encoded string (argon2id, 2a...)
+	ID() string
+	// Hash returns the encoded hash of password
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with weaker parameters
+	// than the hasher currently uses
+	NeedsRehash(encoded string) bool
+}
+
+// IsInvalidHash reports whether err means the stored hash itself is unusable
+// (malformed or produced by an unsupported algorithm), as opposed to an
+// internal failure. Для входа это то же самое, что неверный пароль.
+func IsInvalidHash(err error) bool {
+	return errors.Is(err, ErrMalformedHash) || errors.Is(err, ErrUnknownAlgorithm)
+}
+
+// Default is the hasher used for new passwords and for upgrading old ones
+var Default Hasher = NewArgon2id(DefaultArgon2idParams)
+
+// hashers lists every algorithm we can still verify, keyed by ID
+var hashers = map[string]Hasher{
+	"argon2id": NewArgon2id(DefaultArgon2idParams),
+	"2a":       NewBcrypt(DefaultBcryptCost),
+	"2b":       NewBcrypt(DefaultBcryptCost),
+	"2y":       NewBcrypt(DefaultBcryptCost),
+}
+
 func GenerateSalt(length int) ([]byte, error) {
 	salt := make([]byte, length)
 	_, err := rand.Read(salt)
@@ -14,9 +53,33 @@ func GenerateSalt(length int) ([]byte, error) {
 	return salt, nil
 }
 
-func HashPassword(password string, salt []byte) (hash []byte) {
-	data := append([]byte(password), salt...)
-	hash1 := sha256.Sum256(data)
-	hash = hash1[:]
-	return hash
+// Hash hashes password with the Default hasher
+func Hash(password string) (string, error) {
+	return Default.Hash(password)
+}
+
+// Verify checks password against a stored hash.
+// encoded is either a PHC-style string ("$argon2id$...", "$2a$...") or a legacy
+// hex SHA-256 digest, in which case legacySalt must hold the hex salt from the
+// users.salt column.
+// needsRehash is true when the password matched but the stored hash should be
+// replaced with one produced by Default.
+func Verify(password, encoded, legacySalt string) (ok, needsRehash bool, err error) {
+	if !strings.HasPrefix(encoded, "$") {
+		ok, err = verifyLegacySHA256(password, encoded, legacySalt)
+		return ok, ok, err
+	}
+
+	id, _, _ := strings.Cut(encoded[1:], "$")
+	h, found := hashers[id]
+	if !found {
+		return false, false, ErrUnknownAlgorithm
+	}
+
+	ok, err = h.Verify(password, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	return true, h.ID() != Default.ID() || Default.NeedsRehash(encoded), nil
 }
@@ -0,0 +1,158 @@
+package password
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Слабые параметры argon2id, чтобы тесты шли быстро; такие хэши должны
+// перехэшироваться параметрами Default
+var weakArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func mustHash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	return encoded
+}
+
+func legacyHash(password, saltHex string) string {
+	salt, _ := hex.DecodeString(saltHex)
+	sum := sha256.Sum256(append([]byte(password), salt...))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerify(t *testing.T) {
+	const (
+		secret     = "correct horse battery staple"
+		legacySalt = "a1b2c3d4e5f60718"
+	)
+
+	defaultHash := mustHash(t, Default, secret)
+	weakArgon := mustHash(t, NewArgon2id(weakArgon2idParams), secret)
+	bcryptHash := mustHash(t, NewBcrypt(bcrypt.MinCost), secret)
+	legacy := legacyHash(secret, legacySalt)
+
+	tests := []struct {
+		name        string
+		password    string
+		encoded     string
+		salt        string
+		ok          bool
+		needsRehash bool
+		err         error
+	}{
+		{name: "argon2id default", password: secret, encoded: defaultHash, ok: true},
+		{name: "argon2id default wrong password", password: "wrong", encoded: defaultHash},
+		{name: "argon2id weak params", password: secret, encoded: weakArgon, ok: true, needsRehash: true},
+		{name: "argon2id weak params wrong password", password: "wrong", encoded: weakArgon},
+		{name: "bcrypt", password: secret, encoded: bcryptHash, ok: true, needsRehash: true},
+		{name: "bcrypt wrong password", password: "wrong", encoded: bcryptHash},
+		{name: "legacy sha256", password: secret, encoded: legacy, salt: legacySalt, ok: true, needsRehash: true},
+		{name: "legacy sha256 wrong password", password: "wrong", encoded: legacy, salt: legacySalt},
+		{name: "legacy sha256 wrong salt", password: secret, encoded: legacy, salt: "00"},
+
+		{name: "legacy bad salt", password: secret, encoded: legacy, salt: "not-hex", err: ErrMalformedHash},
+		{name: "legacy bad hash", password: secret, encoded: "zz", salt: legacySalt, err: ErrMalformedHash},
+		{name: "unknown algorithm", password: secret, encoded: "$scrypt$ln=15$abc$def", err: ErrUnknownAlgorithm},
+		{name: "argon2id missing parts", password: secret, encoded: "$argon2id$v=19$m=1024,t=1,p=1", err: ErrMalformedHash},
+		{
+			name:     "argon2id unsupported version",
+			password: secret,
+			encoded:  "$argon2id$v=16$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+			err:      ErrUnknownAlgorithm,
+		},
+		{
+			name:     "argon2id zero iterations",
+			password: secret,
+			encoded:  "$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+			err:      ErrMalformedHash,
+		},
+		{
+			name:     "argon2id bad base64",
+			password: secret,
+			encoded:  "$argon2id$v=19$m=1024,t=1,p=1$!!!$aGFzaA",
+			err:      ErrMalformedHash,
+		},
+		{name: "bcrypt truncated", password: secret, encoded: "$2a$04$short", err: ErrMalformedHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := Verify(tt.password, tt.encoded, tt.salt)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil && !IsInvalidHash(err) {
+				t.Errorf("IsInvalidHash(%v) = false", err)
+			}
+			if ok != tt.ok {
+				t.Errorf("ok = %v, want %v", ok, tt.ok)
+			}
+			if needsRehash != tt.needsRehash {
+				t.Errorf("needsRehash = %v, want %v", needsRehash, tt.needsRehash)
+			}
+		})
+	}
+}
+
+func TestRehashRoundTrip(t *testing.T) {
+	const secret = "hunter2"
+
+	// Пароль из устаревшего хэша после перехэширования проверяется без апгрейда
+	legacy := legacyHash(secret, "00ff")
+	ok, needsRehash, err := Verify(secret, legacy, "00ff")
+	if err != nil || !ok || !needsRehash {
+		t.Fatalf("legacy Verify = %v, %v, %v", ok, needsRehash, err)
+	}
+
+	upgraded, err := Hash(secret)
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	ok, needsRehash, err = Verify(secret, upgraded, "")
+	if err != nil || !ok || needsRehash {
+		t.Fatalf("upgraded Verify = %v, %v, %v", ok, needsRehash, err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := NewArgon2id(DefaultArgon2idParams)
+	weak := mustHash(t, NewArgon2id(weakArgon2idParams), "pw")
+	strong := mustHash(t, argon, "pw")
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{name: "argon2id same params", hasher: argon, encoded: strong, want: false},
+		{name: "argon2id weaker params", hasher: argon, encoded: weak, want: true},
+		{name: "argon2id malformed", hasher: argon, encoded: "$argon2id$garbage", want: true},
+		{name: "bcrypt lower cost", hasher: NewBcrypt(bcrypt.MinCost + 1), encoded: mustHash(t, NewBcrypt(bcrypt.MinCost), "pw"), want: true},
+		{name: "bcrypt same cost", hasher: NewBcrypt(bcrypt.MinCost), encoded: mustHash(t, NewBcrypt(bcrypt.MinCost), "pw"), want: false},
+		{name: "bcrypt malformed", hasher: NewBcrypt(bcrypt.MinCost), encoded: "$2a$xx", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
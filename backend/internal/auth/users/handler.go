@@ -1,8 +1,6 @@
 package users
 
 import (
-	"bytes"
-	"encoding/hex"
 	"net/http"
 
 	"github.com/alexedwards/scs/v2"
@@ -11,6 +9,7 @@ import (
 	"go.uber.org/zap"
 
 	"main/internal/auth/password"
+	"main/internal/pagination"
 	"main/internal/pg"
 )
 
@@ -70,10 +69,17 @@ func RegisterUser(c *gin.Context) {
 		return
 	}
 
-	salt, _ := password.GenerateSalt(32)
-	hash := password.HashPassword(req.Password, salt)
+	hash, err := password.Hash(req.Password)
+	if err != nil {
+		zap.S().Errorw("Register: failed to hash password", "error", err)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to create user"},
+		)
+		return
+	}
 
-	err = pg.InsertInDB(req.Login, req.Email, hash, salt)
+	err = pg.InsertInDB(req.Login, req.Email, hash)
 	if err != nil {
 		zap.S().Errorw("Register: failed to insert user", "error", err)
 		c.JSON(
@@ -95,7 +101,6 @@ func AuthorizeUser(c *gin.Context, sessionManager *scs.SessionManager) {
 	}
 
 	var userData UserData
-	// Read hex strings from the DB
 	err := pg.DB.QueryRow("SELECT id, password_hash, salt FROM users WHERE username = $1 OR email = $1", req.Login).
 		Scan(&userData.ID, &userData.PasswordHash, &userData.Salt)
 	if err != nil {
@@ -105,19 +110,15 @@ func AuthorizeUser(c *gin.Context, sessionManager *scs.SessionManager) {
 		return
 	}
 
-	// Decode hex strings back to bytes
-	saltBytes, err := hex.DecodeString(userData.Salt)
-	if err != nil {
-		zap.S().Errorw("Failed to decode salt from hex", "error", err)
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "Internal server error"},
-		)
+	ok, needsRehash, err := password.Verify(req.Password, userData.PasswordHash, userData.Salt)
+	if err != nil && password.IsInvalidHash(err) {
+		// Битый или неподдерживаемый хэш не должен отличаться от неверного пароля
+		zap.S().Warnw("Authorization: unusable password hash", "error", err, "userID", userData.ID)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
-	hashBytes, err := hex.DecodeString(userData.PasswordHash)
 	if err != nil {
-		zap.S().Errorw("Failed to decode hash from hex", "error", err)
+		zap.S().Errorw("Failed to verify password hash", "error", err, "userID", userData.ID)
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "Internal server error"},
@@ -125,8 +126,18 @@ func AuthorizeUser(c *gin.Context, sessionManager *scs.SessionManager) {
 		return
 	}
 
-	// Compare the byte slices
-	if bytes.Equal(password.HashPassword(req.Password, saltBytes), hashBytes) {
+	if ok {
+		// Transparently migrate legacy and outdated hashes on successful login
+		if needsRehash {
+			if newHash, err := password.Hash(req.Password); err != nil {
+				zap.S().Errorw("Failed to rehash password", "error", err, "userID", userData.ID)
+			} else if err := pg.UpdatePasswordHash(userData.ID, newHash); err != nil {
+				zap.S().Errorw("Failed to store rehashed password", "error", err, "userID", userData.ID)
+			} else {
+				zap.S().Infow("Authorization: password hash upgraded", "userID", userData.ID)
+			}
+		}
+
 		err = sessionManager.RenewToken(c.Request.Context())
 		if err != nil {
 			zap.S().Errorw("Failed to renew session token", "error", err)
@@ -146,8 +157,15 @@ func AuthorizeUser(c *gin.Context, sessionManager *scs.SessionManager) {
 	}
 }
 
+// GetAllUsers lists registered users in registration order
+// GET /api/users?limit=50&cursor=...&total=1
 func GetAllUsers(c *gin.Context) {
-	rows, err := pg.DB.Query("SELECT id, username FROM users ORDER BY id ASC")
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	list, info, err := pg.ListUsers(c.Request.Context(), opts)
 	if err != nil {
 		zap.S().Errorw("Failed to query users from database", "error", err)
 		c.JSON(
@@ -156,32 +174,13 @@ func GetAllUsers(c *gin.Context) {
 		)
 		return
 	}
-	defer rows.Close()
-
-	var users []User
-	for rows.Next() {
-		var user User
-		if err := rows.Scan(&user.ID, &user.Username); err != nil {
-			zap.S().Errorw("Failed to scan user row", "error", err)
-			c.JSON(
-				http.StatusInternalServerError,
-				gin.H{"error": "Failed to process database results"},
-			)
-			return
-		}
-		users = append(users, user)
-	}
 
-	if err = rows.Err(); err != nil {
-		zap.S().Errorw("Error during rows iteration", "error", err)
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "Database iteration failed"},
-		)
-		return
+	users := make([]User, len(list))
+	for i, u := range list {
+		users[i] = User{ID: int(u.ID), Username: u.Username}
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, pagination.Response("users", users, opts, info))
 }
 
 func LogoutUser(c *gin.Context, sessionManager *scs.SessionManager) {
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Pool - пул соединений с Dragonfly, задаётся в main (тот же, что у сессий)
+var Pool *redis.Pool
+
+// GetJSON loads a cached value into dst. found is false on a cache miss.
+func GetJSON(ctx context.Context, key string, dst any) (found bool, err error) {
+	if Pool == nil {
+		return false, nil
+	}
+
+	conn, err := Pool.GetContext(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get cache connection: %w", err)
+	}
+	defer conn.Close()
+
+	data, err := redis.Bytes(conn.Do("GET", key))
+	if errors.Is(err, redis.ErrNil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s from cache: %w", key, err)
+	}
+
+	if err := json.Unmarshal(data, dst); err != nil {
+		return false, fmt.Errorf("failed to decode cached %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// SetJSON stores value under key for ttl
+func SetJSON(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if Pool == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for cache: %w", key, err)
+	}
+
+	conn, err := Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cache connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", key, data, "PX", ttl.Milliseconds()); err != nil {
+		return fmt.Errorf("failed to write %s to cache: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes keys from the cache
+func Delete(ctx context.Context, keys ...string) error {
+	if Pool == nil || len(keys) == 0 {
+		return nil
+	}
+
+	conn, err := Pool.GetContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cache connection: %w", err)
+	}
+	defer conn.Close()
+
+	args := make([]any, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	if _, err := conn.Do("DEL", args...); err != nil {
+		return fmt.Errorf("failed to delete cache keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FriendSuggestionsTTL - сколько живут посчитанные рекомендации друзей
+const FriendSuggestionsTTL = 15 * time.Minute
+
+// FriendSuggestionsKey is the cache key of a user's friend suggestions
+func FriendSuggestionsKey(userID int64) string {
+	return "friends:suggestions:" + strconv.FormatInt(userID, 10)
+}
+
+// InvalidateFriendSuggestions drops cached suggestions of the given users.
+// Errors are only logged: stale suggestions expire with the TTL anyway.
+func InvalidateFriendSuggestions(ctx context.Context, userIDs ...int64) {
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = FriendSuggestionsKey(id)
+	}
+	if err := Delete(ctx, keys...); err != nil {
+		zap.S().Warnw("Failed to invalidate friend suggestions", "error", err, "user_ids", userIDs)
+	}
+}
@@ -5,41 +5,57 @@ import (
 	"net/http"
 	"strconv"
 
+	"main/internal/auth/identity"
+	"main/internal/middleware"
 	"main/internal/models"
+	"main/internal/pagination"
 	"main/internal/pg"
 
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	ErrCommentNotFound = errors.New("comment not found")
+// Ответы в режиме дерева: по умолчанию и максимум на одну ветку
+const (
+	defaultRepliesLimit = 3
+	maxRepliesLimit     = 20
 )
 
-// CreateComment creates a new comment on a post
+// CreateComment creates a new comment on a post or a reply to a comment
 // POST /api/posts/:postID/comments
-// Requires: UserID in context
+// Body: {"content": "...", "parent_id": 12} - parent_id only for replies
+// Requires: authenticated principal in context
 func CreateComment(c *gin.Context) {
-	userID, exists := c.Get("UserID")
-	if !exists {
+	principal, ok := identity.Get(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	username, exists := c.Get("Username")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "username not found"})
+	post, ok := loadPost(c)
+	if !ok {
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
+	// Заблокированный автором поста (или владельцем стены) не может комментировать
+	blocked, err := blockedByPostOwner(c, post, principal.ID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to create comment"},
+		)
+		return
+	}
+	if blocked {
+		c.JSON(
+			http.StatusForbidden,
+			gin.H{"error": "you cannot comment on this post"},
+		)
 		return
 	}
 
 	var req struct {
-		Content string `json:"content" binding:"required,min=1,max=5000"`
+		Content  string `json:"content" binding:"required,min=1,max=5000"`
+		ParentID *int64 `json:"parent_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -48,13 +64,25 @@ func CreateComment(c *gin.Context) {
 	}
 
 	comment := &models.Comment{
-		PostID:   postID,
-		UserID:   userID.(int64),
-		Username: username.(string),
+		PostID:   post.ID,
+		ParentID: req.ParentID,
+		UserID:   principal.ID,
+		Username: principal.Username,
 		Content:  req.Content,
 	}
 
 	if err := pg.CreateComment(c.Request.Context(), comment); err != nil {
+		if errors.Is(err, pg.ErrParentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "parent comment not found"})
+			return
+		}
+		if errors.Is(err, pg.ErrMaxDepth) {
+			c.JSON(
+				http.StatusUnprocessableEntity,
+				gin.H{"error": "reply depth limit reached"},
+			)
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to create comment"},
@@ -68,38 +96,142 @@ func CreateComment(c *gin.Context) {
 	})
 }
 
+// loadPost returns the post resolved by the route's scoping middleware
+// (community.ScopePost or profile.ScopeWallPost), which already checked that
+// the caller can see it
+func loadPost(c *gin.Context) (*models.Post, bool) {
+	post, ok := middleware.Post(c)
+	if !ok {
+		// Маршрут зарегистрирован без middleware - ошибка конфигурации
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch post"},
+		)
+	}
+	return post, ok
+}
+
+// loadComment resolves the comment from the :commentID route parameter and
+// responds 404 if it belongs to a different post
+func loadComment(c *gin.Context, post *models.Post) (*models.Comment, bool) {
+	commentID, err := strconv.ParseInt(c.Param("commentID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return nil, false
+	}
+
+	comment, err := pg.GetCommentByID(c.Request.Context(), commentID, identity.UserID(c))
+	if err != nil {
+		if errors.Is(err, pg.ErrCommentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+			return nil, false
+		}
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch comment"},
+		)
+		return nil, false
+	}
+
+	if comment.PostID != post.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// blockedByPostOwner reports whether the post author or the owner of the
+// wall the post is on has blocked userID
+func blockedByPostOwner(c *gin.Context, post *models.Post, userID int64) (bool, error) {
+	blocked, err := pg.HasBlocked(c.Request.Context(), post.AuthorID, userID)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	if post.TargetType == models.PostTargetUser && post.WallUserID != post.AuthorID {
+		return pg.HasBlocked(c.Request.Context(), post.WallUserID, userID)
+	}
+	return false, nil
+}
+
 // GetCommentsByPostID retrieves all comments for a specific post
-// GET /api/posts/:postID/comments?limit=20&offset=0
+// GET /api/posts/:postID/comments?limit=20&cursor=...&total=1
+// С ?tree=1 отдаёт ветки: страницу комментариев верхнего уровня с вложенными
+// ответами (не больше replies_limit на каждый уровень ветки)
 func GetCommentsByPostID(c *gin.Context) {
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
+	post, ok := loadPost(c)
+	if !ok {
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 20)
+	if !ok {
+		return
+	}
+
+	if tree, _ := strconv.ParseBool(c.Query("tree")); tree {
+		getCommentTree(c, post.ID, nil, opts)
+		return
+	}
+
+	comments, info, err := pg.GetCommentsByPostID(
+		c.Request.Context(),
+		post.ID,
+		identity.UserID(c),
+		opts,
+	)
+
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch comments"},
+		)
 		return
 	}
 
-	limit := 20
-	offset := 0
+	c.JSON(http.StatusOK, pagination.Response("comments", comments, opts, info))
+}
 
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
+// GetCommentReplies continues a single thread: direct replies of a comment
+// with their own nested replies, as in tree mode
+// GET /api/posts/:postID/comments/:commentID/replies?limit=20&cursor=...&replies_limit=3
+func GetCommentReplies(c *gin.Context) {
+	post, ok := loadPost(c)
+	if !ok {
+		return
 	}
 
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
+	parent, ok := loadComment(c, post)
+	if !ok {
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 20)
+	if !ok {
+		return
+	}
+
+	getCommentTree(c, post.ID, &parent.ID, opts)
+}
+
+// getCommentTree writes a page of threads under parentID (nil - top level)
+func getCommentTree(c *gin.Context, postID int64, parentID *int64, opts pg.PageOptions) {
+	repliesLimit := defaultRepliesLimit
+	if l := c.Query("replies_limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed >= 0 &&
+			parsed <= maxRepliesLimit {
+			repliesLimit = parsed
 		}
 	}
 
-	comments, total, err := pg.GetCommentsByPostID(
+	comments, info, err := pg.GetCommentTree(
 		c.Request.Context(),
 		postID,
-		limit,
-		offset,
+		parentID,
+		identity.UserID(c),
+		opts,
+		repliesLimit,
 	)
-
 	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
@@ -108,34 +240,19 @@ func GetCommentsByPostID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"comments": comments,
-		"total":    total,
-		"limit":    limit,
-		"offset":   offset,
-	})
+	c.JSON(http.StatusOK, pagination.Response("comments", comments, opts, info))
 }
 
 // GetComment retrieves a single comment by ID
 // GET /api/posts/:postID/comments/:commentID
 func GetComment(c *gin.Context) {
-	commentIDParam := c.Param("commentID")
-	commentID, err := strconv.ParseInt(commentIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+	post, ok := loadPost(c)
+	if !ok {
 		return
 	}
 
-	comment, err := pg.GetCommentByID(c.Request.Context(), commentID)
-	if err != nil {
-		if errors.Is(err, ErrCommentNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch comment"},
-		)
+	comment, ok := loadComment(c, post)
+	if !ok {
 		return
 	}
 
@@ -144,36 +261,31 @@ func GetComment(c *gin.Context) {
 
 // UpdateComment updates a comment
 // PUT /api/posts/:postID/comments/:commentID
-// Requires: UserID in context (must be comment author)
+// Requires: authenticated principal in context (must be comment author)
 func UpdateComment(c *gin.Context) {
-	userID, exists := c.Get("UserID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	commentIDParam := c.Param("commentID")
-	commentID, err := strconv.ParseInt(commentIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+	post, ok := loadPost(c)
+	if !ok {
 		return
 	}
 
-	comment, err := pg.GetCommentByID(c.Request.Context(), commentID)
-	if err != nil {
-		if errors.Is(err, ErrCommentNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch comment"},
-		)
+	comment, ok := loadComment(c, post)
+	if !ok {
+		return
+	}
+
+	if comment.Deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
 		return
 	}
 
 	// Check ownership
-	if comment.UserID != userID.(int64) {
+	if comment.UserID != userID {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only edit your own comments"},
@@ -208,36 +320,33 @@ func UpdateComment(c *gin.Context) {
 
 // DeleteComment deletes a comment
 // DELETE /api/posts/:postID/comments/:commentID
-// Requires: UserID in context (must be comment author)
+// Requires: authenticated principal in context (must be comment author or community admin)
+// Комментарий с ответами остаётся в ветке заглушкой "[deleted]"
 func DeleteComment(c *gin.Context) {
-	userID, exists := c.Get("UserID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	commentIDParam := c.Param("commentID")
-	commentID, err := strconv.ParseInt(commentIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+	post, ok := loadPost(c)
+	if !ok {
 		return
 	}
 
-	comment, err := pg.GetCommentByID(c.Request.Context(), commentID)
-	if err != nil {
-		if errors.Is(err, ErrCommentNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch comment"},
-		)
+	comment, ok := loadComment(c, post)
+	if !ok {
 		return
 	}
 
-	// Check ownership
-	if comment.UserID != userID.(int64) {
+	if comment.Deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		return
+	}
+
+	// Check ownership; community admins may moderate comments
+	if comment.UserID != userID &&
+		!middleware.HasCommunityRole(middleware.CommunityRole(c), models.RoleAdmin) {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only delete your own comments"},
@@ -245,7 +354,11 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if err := pg.DeleteComment(c.Request.Context(), commentID); err != nil {
+	if err := pg.DeleteComment(c.Request.Context(), comment.ID); err != nil {
+		if errors.Is(err, pg.ErrCommentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to delete comment"},
@@ -255,3 +368,111 @@ func DeleteComment(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "comment deleted successfully"})
 }
+
+// AddReaction puts a reaction on a comment
+// POST /api/posts/:postID/comments/:commentID/reactions
+// Body: {"reaction": "love"}
+// Requires: authenticated principal in context
+func AddReaction(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	post, ok := loadPost(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := loadComment(c, post)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Reaction string `json:"reaction" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidReaction(req.Reaction) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
+		return
+	}
+
+	err := pg.AddCommentReaction(c.Request.Context(), comment.ID, userID, req.Reaction)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrCommentNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "comment not found"})
+		case errors.Is(err, pg.ErrAlreadyReacted):
+			c.JSON(
+				http.StatusConflict,
+				gin.H{"error": "you already left this reaction"},
+			)
+		default:
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "failed to add reaction"},
+			)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reaction added successfully"})
+}
+
+// RemoveReaction removes the caller's reaction from a comment
+// DELETE /api/posts/:postID/comments/:commentID/reactions?reaction=love
+// Requires: authenticated principal in context
+func RemoveReaction(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	post, ok := loadPost(c)
+	if !ok {
+		return
+	}
+
+	comment, ok := loadComment(c, post)
+	if !ok {
+		return
+	}
+
+	reaction := c.Query("reaction")
+	if !models.IsValidReaction(reaction) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
+		return
+	}
+
+	err := pg.RemoveCommentReaction(c.Request.Context(), comment.ID, userID, reaction)
+	if err != nil {
+		if errors.Is(err, pg.ErrNotReacted) {
+			c.JSON(
+				http.StatusNotFound,
+				gin.H{"error": "you haven't left this reaction"},
+			)
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to remove reaction"},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reaction removed successfully"})
+}
@@ -0,0 +1,211 @@
+package communities
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/middleware"
+	"main/internal/models"
+	"main/internal/pg"
+)
+
+// CreateCommunityHandler creates a community owned by the session user
+// POST /api/community
+func CreateCommunityHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required,max=255"`
+		Description string `json:"description"`
+		IsPrivate   bool   `json:"is_private"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	community := &models.Community{
+		Name:        req.Name,
+		Description: req.Description,
+		IsPrivate:   req.IsPrivate,
+		CreatedBy:   userID,
+	}
+
+	if err := pg.CreateCommunity(c.Request.Context(), community); err != nil {
+		zap.S().Errorw("Failed to create community", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create community"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, community)
+}
+
+// GetCommunityHandler returns a community with its admins, writers and
+// subscribers
+// GET /community/:id
+// Закрытое сообщество видят только его подписчики, остальным - 404
+func GetCommunityHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	community, err := middleware.VisibleCommunity(c, communityID)
+	if err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch community", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch community"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	resp := pg.CommunityResponse{
+		ID:          community.ID,
+		Name:        community.Name,
+		Description: community.Description,
+		IsPrivate:   community.IsPrivate,
+		CreatedBy:   community.CreatedBy,
+		CreatedAt:   community.CreatedAt,
+	}
+
+	resp.Subscribers, err = pg.GetCommunitySubscriberNames(ctx, communityID)
+	if err != nil {
+		zap.S().Errorw("Failed to fetch subscribers", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch subscribers"})
+		return
+	}
+
+	resp.Admins, resp.Writers, err = pg.GetCommunityRoles(ctx, communityID)
+	if err != nil {
+		zap.S().Errorw("Failed to get community roles", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch community roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateCommunityHandler renames a community, changes its description or privacy
+// PUT /api/community/:id
+// Требует роль admin в сообществе
+func UpdateCommunityHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	var req struct {
+		Name        *string `json:"name" binding:"omitempty,min=1,max=255"`
+		Description *string `json:"description"`
+		IsPrivate   *bool   `json:"is_private"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	community, err := pg.GetCommunity(c.Request.Context(), communityID)
+	if err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch community"})
+		return
+	}
+
+	// Partial update - обновляем только переданные поля
+	if req.Name != nil {
+		community.Name = *req.Name
+	}
+	if req.Description != nil {
+		community.Description = *req.Description
+	}
+	if req.IsPrivate != nil {
+		community.IsPrivate = *req.IsPrivate
+	}
+
+	if err := pg.UpdateCommunity(c.Request.Context(), community); err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to update community", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update community"})
+		return
+	}
+
+	community.Admins, community.Writers, err = pg.GetCommunityRoles(c.Request.Context(), communityID)
+	if err != nil {
+		zap.S().Errorw("Failed to get community roles", "error", err, "community_id", communityID)
+	}
+
+	c.JSON(http.StatusOK, community)
+}
+
+// DeleteCommunityHandler deletes a community together with its posts and subscriptions
+// DELETE /api/community/:id
+// Требует роль admin в сообществе
+func DeleteCommunityHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	if err := pg.DeleteCommunity(c.Request.Context(), communityID); err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to delete community", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete community"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RecommendedHandler suggests communities to join based on how many
+// subscribers they share with the communities the session user follows
+// GET /api/communities/recommended?limit=10
+func RecommendedHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := 10
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 50 {
+			limit = parsed
+		}
+	}
+
+	recommendations, err := pg.GetRecommendedCommunities(c.Request.Context(), userID, limit)
+	if err != nil {
+		zap.S().Errorw("Failed to get community recommendations", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve recommendations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recommendations)
+}
@@ -7,30 +7,114 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"main/internal/auth/identity"
+	"main/internal/media"
+	"main/internal/middleware"
 	"main/internal/models"
+	"main/internal/pagination"
 	"main/internal/pg"
 )
 
-var (
-	ErrPostNotFound = errors.New("post not found")
-	ErrAlreadyLiked = errors.New("already liked")
-	ErrNotLiked     = errors.New("not liked")
-)
+// loadCommunity resolves the community from the :id route parameter and
+// checks that the caller can see it: private communities are visible only
+// to their subscribers, writers and admins.
+// Writes the error response and returns false on failure.
+func loadCommunity(c *gin.Context) (*models.Community, bool) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community ID"})
+		return nil, false
+	}
+
+	community, err := pg.GetCommunity(c.Request.Context(), communityID)
+	if err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return nil, false
+		}
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch community"},
+		)
+		return nil, false
+	}
+
+	if !middleware.CanViewCommunity(c, community) {
+		c.JSON(
+			http.StatusForbidden,
+			gin.H{"error": "this community is private"},
+		)
+		return nil, false
+	}
+
+	return community, true
+}
+
+// loadCommunityPost resolves the post from the :postID route parameter and
+// responds 404 if it belongs to a different community than the one in the URL
+func loadCommunityPost(c *gin.Context, community *models.Community) (*models.Post, bool) {
+	postID, err := strconv.ParseInt(c.Param("postID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return nil, false
+	}
+
+	post, err := pg.GetPostByID(c.Request.Context(), postID)
+	if err != nil {
+		if errors.Is(err, pg.ErrPostNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+			return nil, false
+		}
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch post"},
+		)
+		return nil, false
+	}
+
+	if post.Target() != models.CommunityFeed(community.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return nil, false
+	}
+
+	return post, true
+}
+
+// ScopePost resolves the community and the post for routes nested under a
+// community post (comments) and stores the post with middleware.SetPost.
+// Приватное сообщество и пост из другого сообщества обрываются так же,
+// как в обработчиках постов.
+func ScopePost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		community, ok := loadCommunity(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		post, ok := loadCommunityPost(c, community)
+		if !ok {
+			c.Abort()
+			return
+		}
 
-// Handler handles HTTP requests for profile posts
-// CreatePost creates a new post
-// POST /api/profile/posts
-// Требует авторизацию (userID в контексте)
+		middleware.SetPost(c, post)
+		c.Next()
+	}
+}
+
+// CreatePost creates a new post in a community
+// POST /api/community/:id/posts
+// Требует авторизацию и роль writer или admin в сообществе
 func CreatePost(c *gin.Context) {
-	communityID, exists := c.Get("CommunityID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unknown community"})
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	userID, exists := c.Get("UserID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
@@ -38,6 +122,8 @@ func CreatePost(c *gin.Context) {
 		Title  string `json:"title" binding:"required,max=255"`
 		Text   string `json:"text" binding:"required"`
 		PicURL string `json:"pic_url"`
+		// Загруженные через POST /api/media файлы, в порядке показа
+		MediaIDs []int64 `json:"media_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -45,15 +131,25 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
+	mediaIDs, ok := media.AttachmentIDs(c, req.MediaIDs)
+	if !ok {
+		return
+	}
+
 	post := &models.Post{
 		Title:       req.Title,
 		Text:        req.Text,
 		PicURL:      req.PicURL,
-		CommunityID: communityID.(int64), // Для профиля - CommunityID = UserID
-		AuthorID:    userID.(int64),
+		TargetType:  models.PostTargetCommunity,
+		CommunityID: community.ID,
+		AuthorID:    userID,
+		MediaIDs:    mediaIDs,
 	}
 
 	if err := pg.CreatePost(c.Request.Context(), post); err != nil {
+		if media.RespondAttachError(c, err) {
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to create post"},
@@ -67,37 +163,23 @@ func CreatePost(c *gin.Context) {
 	})
 }
 
-// GetUserPosts retrieves all posts for a user
-// GET /api/profile/:userID/posts?limit=20&offset=40
-func GetUserPosts(c *gin.Context) {
-	userIDParam := c.Param("id")
-	userID, err := strconv.ParseInt(userIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+// GetCommunityPosts retrieves all posts of a community
+// GET /community/:id/posts?limit=20&cursor=...&total=1
+func GetCommunityPosts(c *gin.Context) {
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
-	limit := 10
-	offset := 0
-
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 &&
-			parsed <= 100 {
-			limit = parsed
-		}
-	}
-
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	opts, ok := pagination.FromQuery(c, 10)
+	if !ok {
+		return
 	}
 
-	posts, total, err := pg.GetUserPosts(
+	posts, info, err := pg.GetPosts(
 		c.Request.Context(),
-		userID,
-		limit,
-		offset,
+		models.CommunityFeed(community.ID),
+		opts,
 	)
 	if err != nil {
 		c.JSON(
@@ -107,30 +189,43 @@ func GetUserPosts(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"posts":  posts,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	if err := pg.LoadPostStats(c.Request.Context(), posts, identity.UserID(c)); err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch posts"},
+		)
+		return
+	}
+
+	if err := pg.LoadPostMedia(c.Request.Context(), posts); err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch posts"},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("posts", posts, opts, info))
 }
 
 // GetPost retrieves a single post
-// GET /api/profile/posts/:postID
+// GET /community/:id/posts/:postID
 func GetPost(c *gin.Context) {
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
+		return
+	}
+
+	err := pg.LoadPostStats(c.Request.Context(), []*models.Post{post}, identity.UserID(c))
+	if err == nil {
+		err = pg.LoadPostMedia(c.Request.Context(), []*models.Post{post})
+	}
 	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to fetch post"},
@@ -142,37 +237,27 @@ func GetPost(c *gin.Context) {
 }
 
 // UpdatePost updates a post
-// PUT /api/profile/posts/:postID
+// PUT /api/community/:id/posts/:postID
 // Требует авторизацию + проверку владельца
 func UpdatePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
-	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
-		)
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
 		return
 	}
 
 	// Проверяем владельца поста
-	if post.AuthorID != userID.(int64) {
+	if post.AuthorID != userID {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only edit your own posts"},
@@ -217,37 +302,28 @@ func UpdatePost(c *gin.Context) {
 }
 
 // DeletePost deletes a post
-// DELETE /api/profile/posts/:postID
-// Требует авторизацию + проверку владельца
+// DELETE /api/community/:id/posts/:postID
+// Требует авторизацию + проверку владельца или роль admin
 func DeletePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
-	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
-		)
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
 		return
 	}
 
-	// Проверяем владельца
-	if post.AuthorID != userID.(int64) {
+	// Удалять может автор или админ сообщества (модерация)
+	if post.AuthorID != userID &&
+		!middleware.HasCommunityRole(middleware.CommunityRole(c), models.RoleAdmin) {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only delete your own posts"},
@@ -255,7 +331,7 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	if err := pg.DeletePost(c.Request.Context(), postID); err != nil {
+	if err := pg.DeletePost(c.Request.Context(), post.ID); err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to delete post"},
@@ -266,86 +342,147 @@ func DeletePost(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "post deleted successfully"})
 }
 
-// LikePost adds a like to a post
-// POST /api/profile/posts/:postID/like
+// LikePost puts the "like" reaction on a post
+// POST /api/community/:id/posts/:postID/like
 // Требует авторизацию
 func LikePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	setReaction(c, models.ReactionLike)
+}
+
+// SetReaction sets or changes the caller's reaction on a post
+// PUT /api/community/:id/posts/:postID/reaction
+// Body: {"reaction": "love"}
+// Требует авторизацию
+func SetReaction(c *gin.Context) {
+	var req struct {
+		Reaction string `json:"reaction" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidReaction(req.Reaction) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
+		return
+	}
+
+	setReaction(c, req.Reaction)
+}
+
+func setReaction(c *gin.Context, reaction string) {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	community, ok := loadCommunity(c)
+	if !ok {
+		return
+	}
+
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
 		return
 	}
 
-	// Проверяем существует ли пост
-	_, err = pg.GetPostByID(c.Request.Context(), postID)
+	err := pg.SetPostReaction(c.Request.Context(), post.ID, userID, reaction)
 	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
+		if errors.Is(err, pg.ErrPostNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 			return
 		}
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
+			gin.H{"error": "failed to react to post"},
 		)
 		return
 	}
 
-	if err := pg.LikePost(c.Request.Context(), postID, userID.(int64)); err != nil {
-		if errors.Is(err, ErrAlreadyLiked) {
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "reaction saved successfully",
+		"reaction": reaction,
+	})
+}
+
+// RemoveReaction removes the caller's reaction from a post
+// DELETE /api/community/:id/posts/:postID/reaction (и /like)
+// Требует авторизацию
+func RemoveReaction(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	community, ok := loadCommunity(c)
+	if !ok {
+		return
+	}
+
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
+		return
+	}
+
+	if err := pg.RemovePostReaction(c.Request.Context(), post.ID, userID); err != nil {
+		if errors.Is(err, pg.ErrNoReaction) {
 			c.JSON(
-				http.StatusConflict,
-				gin.H{"error": "you already liked this post"},
+				http.StatusNotFound,
+				gin.H{"error": "you haven't reacted to this post"},
 			)
 			return
 		}
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to like post"},
+			gin.H{"error": "failed to remove reaction"},
 		)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "post liked successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "reaction removed successfully"})
 }
 
-// UnlikePost removes a like from a post
-// DELETE /api/profile/posts/:postID/like
-// Требует авторизацию
-func UnlikePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+// GetReactions lists who reacted to a post, newest first
+// GET /community/:id/posts/:postID/reactions?reaction=love&limit=50&cursor=...
+func GetReactions(c *gin.Context) {
+	community, ok := loadCommunity(c)
+	if !ok {
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	post, ok := loadCommunityPost(c, community)
+	if !ok {
 		return
 	}
 
-	if err := pg.UnlikePost(c.Request.Context(), postID, userID.(int64)); err != nil {
-		if errors.Is(err, ErrNotLiked) {
-			c.JSON(
-				http.StatusNotFound,
-				gin.H{"error": "you haven't liked this post"},
-			)
-			return
-		}
+	kind := c.Query("reaction")
+	if kind != "" && !models.IsValidReaction(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	reactions, info, err := pg.GetPostReactions(c.Request.Context(), post.ID, kind, opts)
+	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to unlike post"},
+			gin.H{"error": "failed to fetch reactions"},
 		)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "post unliked successfully"})
+	c.JSON(http.StatusOK, pagination.Response("reactions", reactions, opts, info))
 }
@@ -0,0 +1,119 @@
+package roles
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/middleware"
+	"main/internal/pg"
+)
+
+// GetRolesHandler lists admins and writers of a community
+// GET /api/community/:id/roles
+func GetRolesHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	if _, err := middleware.VisibleCommunity(c, communityID); err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch community", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve community roles"})
+		return
+	}
+
+	admins, writers, err := pg.GetCommunityRoles(c.Request.Context(), communityID)
+	if err != nil {
+		zap.S().Errorw("Failed to get community roles", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve community roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"admins":  admins,
+		"writers": writers,
+	})
+}
+
+// GrantRoleHandler grants role to a user
+// PUT /api/community/:id/admins/:user_id
+// PUT /api/community/:id/writers/:user_id
+// Требует роль admin в сообществе
+func GrantRoleHandler(c *gin.Context, role string) {
+	communityID, userID, ok := parseIDs(c)
+	if !ok {
+		return
+	}
+
+	err := pg.GrantCommunityRole(c.Request.Context(), communityID, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrRoleExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, pg.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, pg.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			zap.S().Errorw("Failed to grant community role", "error", err, "community_id", communityID, "user_id", userID, "role", role)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to grant role"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "role granted successfully"})
+}
+
+// RevokeRoleHandler revokes role from a user
+// DELETE /api/community/:id/admins/:user_id
+// DELETE /api/community/:id/writers/:user_id
+// Требует роль admin в сообществе
+func RevokeRoleHandler(c *gin.Context, role string) {
+	communityID, userID, ok := parseIDs(c)
+	if !ok {
+		return
+	}
+
+	err := pg.RevokeCommunityRole(c.Request.Context(), communityID, userID, role)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrRoleMissing):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, pg.ErrLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, pg.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			zap.S().Errorw("Failed to revoke community role", "error", err, "community_id", communityID, "user_id", userID, "role", role)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke role"})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseIDs(c *gin.Context) (communityID, userID int64, ok bool) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return 0, 0, false
+	}
+
+	userID, err = strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, 0, false
+	}
+
+	return communityID, userID, true
+}
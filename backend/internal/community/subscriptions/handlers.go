@@ -0,0 +1,230 @@
+package subscriptions
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/middleware"
+	"main/internal/pagination"
+	"main/internal/pg"
+)
+
+// SubscribeHandler subscribes the session user to a community
+// POST /api/community/:id/subscription
+// Для приватных сообществ создаёт заявку на вступление (202)
+func SubscribeHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	status, err := pg.SubscribeToCommunity(c.Request.Context(), userID, communityID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrCommunityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+		case errors.Is(err, pg.ErrAlreadySubscribed):
+			c.JSON(http.StatusConflict, gin.H{"error": "you are already subscribed to this community"})
+		case errors.Is(err, pg.ErrJoinRequestExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "your join request is already pending"})
+		default:
+			zap.S().Errorw("Failed to subscribe to community", "error", err, "user_id", userID, "community_id", communityID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to subscribe"})
+		}
+		return
+	}
+
+	if status == pg.SubscriptionPending {
+		c.JSON(http.StatusAccepted, gin.H{"status": status, "message": "join request sent"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"status": status, "message": "subscribed successfully"})
+}
+
+// UnsubscribeHandler removes the session user's subscription or pending join request
+// DELETE /api/community/:id/subscription
+func UnsubscribeHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	err = pg.UnsubscribeFromCommunity(c.Request.Context(), userID, communityID)
+	if err != nil {
+		if errors.Is(err, pg.ErrNotSubscribed) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "you are not subscribed to this community"})
+			return
+		}
+		zap.S().Errorw("Failed to unsubscribe from community", "error", err, "user_id", userID, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unsubscribe"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetMyCommunitiesHandler lists communities the session user is subscribed to
+// GET /api/me/communities
+func GetMyCommunitiesHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	communities, err := pg.GetUserCommunities(c.Request.Context(), userID)
+	if err != nil {
+		zap.S().Errorw("Failed to get user communities", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retrieve communities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, communities)
+}
+
+// GetSubscribersHandler lists subscribers of a community in subscription order
+// GET /community/:id/subscribers?limit=50&cursor=...&total=1
+// Участники закрытого сообщества видны только его подписчикам; остальным
+// отвечаем 404, чтобы не раскрывать состав
+func GetSubscribersHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	if _, err := middleware.VisibleCommunity(c, communityID); err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch community", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch subscribers"})
+		return
+	}
+
+	subscribers, info, err := pg.GetCommunitySubscribers(c.Request.Context(), communityID, opts)
+	if err != nil {
+		if errors.Is(err, pg.ErrCommunityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch subscribers", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch subscribers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("subscribers", subscribers, opts, info))
+}
+
+// GetJoinRequestsHandler lists pending join requests of a private community
+// GET /api/community/:id/join-requests?limit=50&cursor=...&total=1
+// Только для админов сообщества
+func GetJoinRequestsHandler(c *gin.Context) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	requests, info, err := pg.GetJoinRequests(c.Request.Context(), communityID, opts)
+	if err != nil {
+		zap.S().Errorw("Failed to fetch join requests", "error", err, "community_id", communityID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot fetch join requests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("join_requests", requests, opts, info))
+}
+
+// ApproveJoinRequestHandler subscribes the author of a join request
+// POST /api/community/:id/join-requests/:request_id/approve
+// Только для админов сообщества
+func ApproveJoinRequestHandler(c *gin.Context) {
+	communityID, requestID, ok := parseJoinRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	userID, err := pg.ApproveJoinRequest(c.Request.Context(), communityID, requestID)
+	if err != nil {
+		if errors.Is(err, pg.ErrJoinRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "join request not found"})
+			return
+		}
+		zap.S().Errorw("Failed to approve join request", "error", err, "community_id", communityID, "request_id", requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve join request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "join request approved", "user_id": userID})
+}
+
+// RejectJoinRequestHandler deletes a join request
+// POST /api/community/:id/join-requests/:request_id/reject
+// Только для админов сообщества
+func RejectJoinRequestHandler(c *gin.Context) {
+	communityID, requestID, ok := parseJoinRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	err := pg.RejectJoinRequest(c.Request.Context(), communityID, requestID)
+	if err != nil {
+		if errors.Is(err, pg.ErrJoinRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "join request not found"})
+			return
+		}
+		zap.S().Errorw("Failed to reject join request", "error", err, "community_id", communityID, "request_id", requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject join request"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseJoinRequestIDs(c *gin.Context) (communityID, requestID int64, ok bool) {
+	communityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid community id"})
+		return 0, 0, false
+	}
+
+	requestID, err = strconv.ParseInt(c.Param("request_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request id"})
+		return 0, 0, false
+	}
+
+	return communityID, requestID, true
+}
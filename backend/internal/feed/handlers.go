@@ -0,0 +1,40 @@
+package feed
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/pagination"
+	"main/internal/pg"
+)
+
+// GetFeed returns the session user's home feed
+// GET /api/feed?limit=20&cursor=...
+// Посты друзей и подписанных сообществ, от новых к старым
+func GetFeed(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 20)
+	if !ok {
+		return
+	}
+
+	posts, info, err := pg.GetFeed(c.Request.Context(), userID, opts)
+	if err != nil {
+		zap.S().Errorw("Failed to fetch feed", "error", err, "user_id", userID)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch feed"},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("posts", posts, opts, info))
+}
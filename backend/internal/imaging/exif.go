@@ -0,0 +1,125 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+const exifOrientationTag = 0x0112
+
+// JPEGOrientation reads the EXIF orientation (1-8) of a JPEG file.
+// Без EXIF или при битых данных возвращает 1 - "как есть".
+func JPEGOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF: // заполнитель
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9: // дальше только данные изображения
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			return 1
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation looks the orientation tag up in IFD0 of a TIFF header
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(t[4:]))
+	if offset < 8 || offset+2 > len(t) {
+		return 1
+	}
+
+	count := int(order.Uint16(t[offset:]))
+	for k := 0; k < count; k++ {
+		entry := offset + 2 + k*12
+		if entry+12 > len(t) {
+			return 1
+		}
+		if order.Uint16(t[entry:]) == exifOrientationTag {
+			v := int(order.Uint16(t[entry+8:]))
+			if v < 1 || v > 8 {
+				return 1
+			}
+			return v
+		}
+	}
+
+	return 1
+}
+
+// Orient turns the stored pixels into the upright picture described by the
+// EXIF orientation. Ориентации 5-8 меняют местами ширину и высоту.
+func Orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // отражение по горизонтали
+				sx, sy = w-1-x, y
+			case 3: // поворот на 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // отражение по вертикали
+				sx, sy = x, h-1-y
+			case 5: // транспонирование
+				sx, sy = y, x
+			case 6: // поворот на 90 по часовой
+				sx, sy = y, h-1-x
+			case 7: // транспонирование через другую диагональ
+				sx, sy = w-1-y, h-1-x
+			case 8: // поворот на 90 против часовой
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(b.Min.X+sx, b.Min.Y+sy):])
+		}
+	}
+
+	return dst
+}
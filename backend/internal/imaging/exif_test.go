@@ -0,0 +1,217 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiffIFD0 builds a TIFF header whose IFD0 holds the given entries
+// (tag, value) as SHORT values
+func tiffIFD0(order byteOrder, entries ...[2]uint16) []byte {
+	t := make([]byte, 8, 8+2+12*len(entries)+4)
+	if order == binary.LittleEndian {
+		copy(t, "II")
+	} else {
+		copy(t, "MM")
+	}
+	order.PutUint16(t[2:], 42)
+	order.PutUint32(t[4:], 8)
+
+	t = order.AppendUint16(t, uint16(len(entries)))
+	for _, e := range entries {
+		t = order.AppendUint16(t, e[0])
+		t = order.AppendUint16(t, 3) // SHORT
+		t = order.AppendUint32(t, 1)
+		t = order.AppendUint16(t, e[1])
+		t = order.AppendUint16(t, 0)
+	}
+	return order.AppendUint32(t, 0) // следующего IFD нет
+}
+
+// jpegWithSegments wraps APP segments into a minimal JPEG stream
+func jpegWithSegments(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	s := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(s[2:], uint16(len(payload)+2))
+	return append(s, payload...)
+}
+
+func exifSegment(tiff []byte) []byte {
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for _, order := range []byteOrder{binary.LittleEndian, binary.BigEndian} {
+		for want := 1; want <= 8; want++ {
+			data := jpegWithSegments(exifSegment(tiffIFD0(order,
+				[2]uint16{0x010F, 7}, // Make - до ориентации есть другой тег
+				[2]uint16{exifOrientationTag, uint16(want)},
+			)))
+			if got := JPEGOrientation(data); got != want {
+				t.Errorf("%v orientation %d: got %d", order, want, got)
+			}
+		}
+	}
+}
+
+func TestJPEGOrientationSegments(t *testing.T) {
+	valid := exifSegment(tiffIFD0(binary.BigEndian, [2]uint16{exifOrientationTag, 6}))
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "empty", data: nil, want: 1},
+		{name: "not a jpeg", data: []byte("\x89PNG\r\n\x1a\n"), want: 1},
+		{name: "no exif", data: jpegWithSegments(segment(0xE0, []byte("JFIF\x00\x01\x02"))), want: 1},
+		{
+			name: "exif after other segments",
+			data: jpegWithSegments(
+				segment(0xE0, []byte("JFIF\x00\x01\x02")),
+				segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x/>")),
+				valid,
+			),
+			want: 6,
+		},
+		{name: "fill bytes before marker", data: append([]byte{0xFF, 0xD8, 0xFF}, valid...), want: 6},
+		{name: "exif after start of scan is ignored", data: append([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}, valid...), want: 1},
+		{name: "garbage instead of marker", data: []byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}, want: 1},
+		{name: "segment length below 2", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0x00, 0x00}, want: 1},
+		{name: "segment length past end", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x', 'i', 'f'}, want: 1},
+		{name: "exif header only", data: jpegWithSegments(segment(0xE1, []byte("Exif\x00\x00"))), want: 1},
+		{name: "unknown byte order", data: jpegWithSegments(exifSegment([]byte("XX\x00\x2a\x00\x00\x00\x08\x00\x00"))), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JPEGOrientation(tt.data); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTIFFOrientationBadIFD(t *testing.T) {
+	order := binary.LittleEndian
+	base := tiffIFD0(order, [2]uint16{exifOrientationTag, 3})
+
+	withOffset := func(offset uint32) []byte {
+		t := append([]byte(nil), base...)
+		order.PutUint32(t[4:], offset)
+		return t
+	}
+	withCount := func(count uint16) []byte {
+		t := append([]byte(nil), base...)
+		order.PutUint16(t[8:], count)
+		return t
+	}
+	withValue := func(v uint16) []byte {
+		t := append([]byte(nil), base...)
+		order.PutUint16(t[10+8:], v)
+		return t
+	}
+
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{name: "valid", tiff: base, want: 3},
+		{name: "offset inside header", tiff: withOffset(4), want: 1},
+		{name: "offset past end", tiff: withOffset(uint32(len(base))), want: 1},
+		{name: "offset near uint32 max", tiff: withOffset(0xFFFFFFF0), want: 1},
+		{name: "entry count past end", tiff: append(withCount(0xFFFF)[:10], 0x00, 0x01), want: 1},
+		{name: "orientation zero", tiff: withValue(0), want: 1},
+		{name: "orientation out of range", tiff: withValue(9), want: 1},
+		{name: "short header", tiff: base[:6], want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tiffOrientation(tt.tiff); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+			// Тот же TIFF внутри JPEG
+			if got := JPEGOrientation(jpegWithSegments(exifSegment(tt.tiff))); got != tt.want {
+				t.Errorf("in jpeg: got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// Любой обрезанный префикс корректного файла не должен паниковать
+func TestJPEGOrientationTruncated(t *testing.T) {
+	data := jpegWithSegments(exifSegment(tiffIFD0(binary.BigEndian, [2]uint16{exifOrientationTag, 8})))
+	for n := 0; n < len(data); n++ {
+		got := JPEGOrientation(data[:n])
+		if got < 1 || got > 8 {
+			t.Fatalf("prefix %d: orientation %d out of range", n, got)
+		}
+	}
+}
+
+func FuzzJPEGOrientation(f *testing.F) {
+	f.Add(jpegWithSegments(exifSegment(tiffIFD0(binary.LittleEndian, [2]uint16{exifOrientationTag, 6}))))
+	f.Add(jpegWithSegments(exifSegment(tiffIFD0(binary.BigEndian, [2]uint16{exifOrientationTag, 3}))))
+	f.Add([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0, 0})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if got := JPEGOrientation(data); got < 1 || got > 8 {
+			t.Fatalf("orientation %d out of range", got)
+		}
+	})
+}
+
+func TestOrient(t *testing.T) {
+	// 3x2, каждый пиксель помечен своими координатами
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			src.SetRGBA(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+
+	// Какой исходный пиксель оказывается в левом верхнем углу
+	tests := []struct {
+		orientation int
+		w, h        int
+		topLeft     [2]uint8
+	}{
+		{1, 3, 2, [2]uint8{0, 0}},
+		{2, 3, 2, [2]uint8{2, 0}},
+		{3, 3, 2, [2]uint8{2, 1}},
+		{4, 3, 2, [2]uint8{0, 1}},
+		{5, 2, 3, [2]uint8{0, 0}},
+		{6, 2, 3, [2]uint8{0, 1}},
+		{7, 2, 3, [2]uint8{2, 1}},
+		{8, 2, 3, [2]uint8{2, 0}},
+	}
+
+	for _, tt := range tests {
+		dst := Orient(src, tt.orientation)
+		if dst.Bounds().Dx() != tt.w || dst.Bounds().Dy() != tt.h {
+			t.Errorf("orientation %d: size %v, want %dx%d", tt.orientation, dst.Bounds().Size(), tt.w, tt.h)
+			continue
+		}
+		c := dst.RGBAAt(0, 0)
+		if c.R != tt.topLeft[0] || c.G != tt.topLeft[1] {
+			t.Errorf("orientation %d: top-left is source (%d,%d), want (%d,%d)",
+				tt.orientation, c.R, c.G, tt.topLeft[0], tt.topLeft[1])
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Package imaging validates uploaded images and produces resized copies
+// using only the standard library. Повторное кодирование заодно убирает все
+// метаданные (EXIF, GPS и т.п.) из исходного файла.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// MaxPixels bounds width*height so a tiny file cannot decode into gigabytes
+const MaxPixels = 40_000_000
+
+const jpegQuality = 85
+
+// Принимаемые форматы; декодеры регистрируются импортом image/* пакетов
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// SniffType detects the MIME type from the file contents, ignoring whatever
+// the client claimed
+func SniffType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// IsSupported reports whether Decode accepts the sniffed type
+func IsSupported(contentType string) bool {
+	return supportedTypes[contentType]
+}
+
+// Decode checks the type and dimensions, decodes the image (first frame of a
+// GIF) and applies the EXIF orientation of JPEG photos
+func Decode(data []byte) (*image.RGBA, string, error) {
+	contentType := SniffType(data)
+	if !IsSupported(contentType) {
+		return nil, contentType, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, contentType, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, contentType, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, contentType, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	rgba := toRGBA(img)
+	if contentType == "image/jpeg" {
+		rgba = Orient(rgba, JPEGOrientation(data))
+	}
+
+	return rgba, contentType, nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
+
+// OutputType is the type resized copies are stored in: photos stay JPEG,
+// everything else becomes PNG to keep transparency
+func OutputType(sourceType string) string {
+	if sourceType == "image/jpeg" {
+		return "image/jpeg"
+	}
+	return "image/png"
+}
+
+// Extension returns the file extension for an OutputType
+func Extension(contentType string) string {
+	if contentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Encode writes img as JPEG or PNG
+func Encode(w io.Writer, img image.Image, contentType string) error {
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png":
+		return png.Encode(w, img)
+	default:
+		return ErrUnsupportedType
+	}
+}
+
+// EncodeBytes is Encode into a new buffer
+func EncodeBytes(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, contentType); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Square crops the largest centered square out of img
+func Square(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	offset := image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2)
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, offset, draw.Src)
+	return dst
+}
+
+// Fit scales img down so that neither side exceeds maxSide, keeping the
+// aspect ratio. Маленькие изображения не увеличиваются.
+func Fit(img *image.RGBA, maxSide int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+
+	if w >= h {
+		return Resize(img, maxSide, max(1, h*maxSide/w))
+	}
+	return Resize(img, max(1, w*maxSide/h), maxSide)
+}
+
+// Resize scales src to width x height with area averaging: every output
+// pixel is the coverage-weighted mean of the source pixels under it, which
+// gives clean downscales without a dedicated imaging library.
+func Resize(src *image.RGBA, width, height int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	scaleX := float64(sw) / float64(width)
+	scaleY := float64(sh) / float64(height)
+
+	for y := 0; y < height; y++ {
+		y0, y1 := float64(y)*scaleY, float64(y+1)*scaleY
+		for x := 0; x < width; x++ {
+			x0, x1 := float64(x)*scaleX, float64(x+1)*scaleX
+
+			var sum [4]float64
+			var total float64
+			for sy := int(y0); sy < sh && float64(sy) < y1; sy++ {
+				wy := math.Min(y1, float64(sy+1)) - math.Max(y0, float64(sy))
+				for sx := int(x0); sx < sw && float64(sx) < x1; sx++ {
+					wx := math.Min(x1, float64(sx+1)) - math.Max(x0, float64(sx))
+					weight := wx * wy
+					i := src.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+					for c := 0; c < 4; c++ {
+						sum[c] += float64(src.Pix[i+c]) * weight
+					}
+					total += weight
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8(math.Min(255, sum[c]/total+0.5))
+			}
+		}
+	}
+
+	return dst
+}
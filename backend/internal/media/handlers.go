@@ -0,0 +1,242 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/imaging"
+	"main/internal/middleware"
+	"main/internal/models"
+	"main/internal/pg"
+	"main/internal/storage"
+)
+
+// UploadHandler stores an image that a post can attach later by ID
+// POST /api/media (multipart/form-data, поле "file")
+// Тип определяется по содержимому файла; EXIF вырезается, строятся все
+// варианты из models.MediaVariants. Неприкреплённые файлы удаляются
+// фоновой очисткой (RunOrphanCleanup).
+func UploadHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxUploadSize+64<<10)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file must not exceed 10 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field 'file' is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file must not exceed 10 MB"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	if len(data) > MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file must not exceed 10 MB"})
+		return
+	}
+
+	img, sourceType, err := imaging.Decode(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":    "unsupported media type",
+				"detected": sourceType,
+				"allowed":  []string{"image/jpeg", "image/png", "image/gif"},
+			})
+		case errors.Is(err, imaging.ErrTooManyPixels):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image dimensions are too large"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image"})
+		}
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	m := &models.Media{
+		OwnerID:     userID,
+		StorageKey:  fmt.Sprintf("media/%d/%d", userID, time.Now().UnixNano()),
+		ContentType: imaging.OutputType(sourceType),
+	}
+
+	if err := storeVariants(ctx, m, img); err != nil {
+		zap.S().Errorw("Failed to store media", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+		return
+	}
+
+	if err := pg.CreateMedia(ctx, m); err != nil {
+		zap.S().Errorw("Failed to create media", "error", err, "user_id", userID)
+		deleteFiles(ctx, m)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, m)
+}
+
+// GetHandler serves one variant of a media file
+// GET /media/:id?variant=medium
+// Неприкреплённый файл виден только своему владельцу, прикреплённый - тем,
+// кому виден пост (см. canView); иначе 404
+func GetHandler(c *gin.Context) {
+	mediaID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid media ID"})
+		return
+	}
+
+	variant := c.DefaultQuery("variant", models.DefaultMediaVariant)
+	if _, ok := models.MediaVariants[variant]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown variant", "variants": variantsBySize()})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	m, err := pg.GetMedia(ctx, mediaID)
+	if err != nil {
+		if errors.Is(err, pg.ErrMediaNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch media", "error", err, "media_id", mediaID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch media"})
+		return
+	}
+
+	visible, err := canView(c, m)
+	if err != nil {
+		zap.S().Errorw("Failed to check media access", "error", err, "media_id", mediaID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch media"})
+		return
+	}
+	if !visible {
+		c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+		return
+	}
+
+	// Файлы по ключу никогда не меняются
+	etag := fmt.Sprintf(`"%d-%s"`, m.ID, variant)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	rc, err := Store.Open(ctx, variantKey(m, variant))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "media not found"})
+			return
+		}
+		zap.S().Errorw("Failed to open media", "error", err, "media_id", mediaID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch media"})
+		return
+	}
+	defer rc.Close()
+
+	c.DataFromReader(http.StatusOK, -1, m.ContentType, rc, map[string]string{
+		"ETag":          etag,
+		"Cache-Control": "private, max-age=86400",
+	})
+}
+
+// canView applies the visibility rules of the post handlers to the post m is
+// attached to: a private community's posts are visible to its subscribers,
+// writers and admins, a wall is hidden from the accounts its owner blocked.
+// Анонимный зритель видит вложения стены, как и саму стену (checkWallAccess)
+func canView(c *gin.Context, m *models.Media) (bool, error) {
+	viewerID := identity.UserID(c)
+	if m.PostID == 0 {
+		return m.OwnerID == viewerID, nil
+	}
+
+	ctx := c.Request.Context()
+
+	post, err := pg.GetPostByID(ctx, m.PostID)
+	if err != nil {
+		if errors.Is(err, pg.ErrPostNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if post.TargetType == models.PostTargetCommunity {
+		community, err := pg.GetCommunity(ctx, post.CommunityID)
+		if err != nil {
+			if errors.Is(err, pg.ErrCommunityNotFound) {
+				return false, nil
+			}
+			return false, err
+		}
+		return middleware.CanViewCommunity(c, community), nil
+	}
+
+	if viewerID == 0 || viewerID == post.WallUserID {
+		return true, nil
+	}
+	blocked, err := pg.HasBlocked(ctx, post.WallUserID, viewerID)
+	return !blocked, err
+}
+
+// AttachmentIDs validates the media_ids of a new post: duplicates are
+// dropped keeping the order, more than models.MaxPostMedia is a 400
+func AttachmentIDs(c *gin.Context, ids []int64) ([]int64, bool) {
+	unique := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	if len(unique) > models.MaxPostMedia {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("a post can have at most %d attachments", models.MaxPostMedia),
+		})
+		return nil, false
+	}
+
+	return unique, true
+}
+
+// RespondAttachError answers the attachment errors of pg.CreatePost and
+// reports whether err was one of them
+func RespondAttachError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, pg.ErrMediaNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "media not found"})
+	case errors.Is(err, pg.ErrMediaAttached):
+		c.JSON(http.StatusConflict, gin.H{"error": "media is already attached to a post"})
+	default:
+		return false
+	}
+	return true
+}
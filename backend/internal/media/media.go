@@ -0,0 +1,127 @@
+// Package media handles image uploads that posts attach by ID.
+package media
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"main/internal/imaging"
+	"main/internal/models"
+	"main/internal/pg"
+	"main/internal/storage"
+)
+
+// Store - хранилище файлов вложений, задаётся в main: storage.Local
+// (файловая система) или storage.S3 (S3-совместимые сервисы, в т.ч. MinIO)
+var Store storage.Storage
+
+// MaxUploadSize - максимальный размер загружаемого файла
+const MaxUploadSize = 10 << 20
+
+// variantKey is where one variant of a media file is stored
+func variantKey(m *models.Media, variant string) string {
+	return fmt.Sprintf("%s/%s%s", m.StorageKey, variant, imaging.Extension(m.ContentType))
+}
+
+func variantKeys(m *models.Media) []string {
+	keys := make([]string, 0, len(models.MediaVariants))
+	for variant := range models.MediaVariants {
+		keys = append(keys, variantKey(m, variant))
+	}
+	return keys
+}
+
+// variantsBySize lists the variants from the largest to the smallest
+func variantsBySize() []string {
+	names := make([]string, 0, len(models.MediaVariants))
+	for name := range models.MediaVariants {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Compare(models.MediaVariants[b], models.MediaVariants[a])
+	})
+	return names
+}
+
+// storeVariants writes every variant of img under m.StorageKey and fills
+// Width, Height and SizeBytes from the largest one. Варианты считаются по
+// цепочке от большего к меньшему; при ошибке записанные файлы удаляются.
+func storeVariants(ctx context.Context, m *models.Media, img *image.RGBA) error {
+	for i, variant := range variantsBySize() {
+		img = imaging.Fit(img, models.MediaVariants[variant])
+
+		encoded, err := imaging.EncodeBytes(img, m.ContentType)
+		if err == nil {
+			err = Store.Put(ctx, variantKey(m, variant), bytes.NewReader(encoded), m.ContentType)
+		}
+		if err != nil {
+			deleteFiles(ctx, m)
+			return err
+		}
+
+		if i == 0 {
+			m.Width, m.Height = img.Bounds().Dx(), img.Bounds().Dy()
+			m.SizeBytes = int64(len(encoded))
+		}
+	}
+
+	return nil
+}
+
+func deleteFiles(ctx context.Context, m *models.Media) {
+	if err := Store.Delete(ctx, variantKeys(m)...); err != nil {
+		zap.S().Warnw("Failed to delete media files", "error", err, "media_id", m.ID, "key", m.StorageKey)
+	}
+}
+
+// CleanupOrphans deletes uploads older than grace that are not attached to
+// any post, in batches, and returns how many were removed
+func CleanupOrphans(ctx context.Context, grace time.Duration) (int, error) {
+	const batch = 100
+
+	removed := 0
+	for {
+		orphans, err := pg.DeleteOrphanMedia(ctx, time.Now().Add(-grace), batch)
+		if err != nil {
+			return removed, err
+		}
+
+		for i := range orphans {
+			deleteFiles(ctx, &orphans[i])
+		}
+		removed += len(orphans)
+
+		if len(orphans) < batch {
+			return removed, nil
+		}
+	}
+}
+
+// RunOrphanCleanup calls CleanupOrphans every interval until ctx is done
+func RunOrphanCleanup(ctx context.Context, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := CleanupOrphans(ctx, grace)
+			if err != nil {
+				zap.S().Errorw("Orphan media cleanup failed", "error", err)
+				continue
+			}
+			if removed > 0 {
+				zap.S().Infow("Removed orphan media", "count", removed)
+			}
+		}
+	}
+}
@@ -1,16 +1,27 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/pg"
 )
 
+// AuthMiddleware rejects anonymous requests and stores the authenticated
+// principal (ID, username, community roles) in the gin context.
+// Handlers read it with identity.Get / identity.UserID.
 func AuthMiddleware(sessionManager *scs.SessionManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !sessionManager.Exists(c.Request.Context(), "userID") {
+		if !loadPrincipal(c, sessionManager) {
+			return
+		}
+
+		if _, ok := identity.Get(c); !ok {
 			zap.S().Warnw("Unauthorized access attempt", "path", c.Request.URL.Path)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
@@ -18,3 +29,47 @@ func AuthMiddleware(sessionManager *scs.SessionManager) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthMiddleware loads the principal for public routes when a session
+// exists, but lets anonymous requests through
+func OptionalAuthMiddleware(sessionManager *scs.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !loadPrincipal(c, sessionManager) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// loadPrincipal stores the session user in the context if there is one.
+// Returns false if the request was aborted.
+func loadPrincipal(c *gin.Context, sessionManager *scs.SessionManager) bool {
+	if _, ok := identity.Get(c); ok {
+		return true
+	}
+
+	userID := sessionManager.GetInt64(c.Request.Context(), "userID")
+	if userID == 0 {
+		return true
+	}
+
+	username, roles, err := pg.GetUserIdentity(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pg.ErrUserNotFound) {
+			// Пользователь удалён, а сессия осталась
+			zap.S().Warnw("Session refers to a missing user", "userID", userID)
+			_ = sessionManager.Destroy(c.Request.Context())
+			return true
+		}
+		zap.S().Errorw("Failed to load session user", "error", err, "userID", userID)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+
+	identity.Set(c, &identity.Principal{
+		ID:       userID,
+		Username: username,
+		Roles:    roles,
+	})
+	return true
+}
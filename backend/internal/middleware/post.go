@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"main/internal/models"
+)
+
+// PostKey is the gin context key holding the post addressed by the
+// :postID route parameter, already checked against the route scope
+// (the community from :id or the user's wall)
+const PostKey = "Post"
+
+// SetPost stores a post resolved by a scoping middleware
+func SetPost(c *gin.Context, post *models.Post) {
+	c.Set(PostKey, post)
+}
+
+// Post returns the post stored by SetPost
+func Post(c *gin.Context) (*models.Post, bool) {
+	post, ok := c.Get(PostKey)
+	if !ok {
+		return nil, false
+	}
+	p, ok := post.(*models.Post)
+	return p, ok
+}
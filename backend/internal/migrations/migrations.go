@@ -0,0 +1,305 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Файлы миграций: sql/NNNN_name.up.sql и sql/NNNN_name.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+//go:embed seed.sql
+var seedSQL string
+
+// lockID - ключ pg_advisory_lock, чтобы два процесса не мигрировали одновременно
+const lockID = 7_245_310_001
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var ErrNoDownMigration = errors.New("migration has no down file")
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns all embedded migrations ordered by version
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileNameRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := files.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Up applies all pending migrations, each in its own transaction.
+// Returns the migrations that were applied.
+func Up(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	var done []Migration
+
+	err := withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, applied, err := load(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+					mig.Version, mig.Name,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the last steps applied migrations in reverse order.
+// Returns the migrations that were rolled back.
+func Down(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	var done []Migration
+
+	err := withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, applied, err := load(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("%d_%s: %w", mig.Version, mig.Name, ErrNoDownMigration)
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					`DELETE FROM schema_migrations WHERE version = $1`,
+					mig.Version,
+				)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Force marks every migration up to and including version as applied
+// without running it. Used to adopt a database created by the old init.sql.
+func Force(ctx context.Context, db *sql.DB, version int64) error {
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, _, err := load(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		return inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+				return err
+			}
+			for _, mig := range migrations {
+				if mig.Version > version {
+					break
+				}
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)
+					 ON CONFLICT (version) DO NOTHING`,
+					mig.Version, mig.Name,
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// GetStatus lists all migrations with their apply time, if any
+func GetStatus(ctx context.Context, db *sql.DB) ([]Status, error) {
+	var statuses []Status
+
+	err := withLock(ctx, db, func(conn *sql.Conn) error {
+		migrations, applied, err := load(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			status := Status{Migration: mig}
+			if at, ok := applied[mig.Version]; ok {
+				status.AppliedAt = &at
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Seed inserts development seed data. It is idempotent.
+func Seed(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, seedSQL); err != nil {
+		return fmt.Errorf("failed to seed database: %w", err)
+	}
+	return nil
+}
+
+// load ensures schema_migrations exists and returns embedded and applied migrations
+func load(ctx context.Context, conn *sql.Conn) ([]Migration, map[int64]time.Time, error) {
+	const createQuery = `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+	if _, err := conn.ExecContext(ctx, createQuery); err != nil {
+		return nil, nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	migrations, err := Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+
+	return migrations, applied, rows.Err()
+}
+
+// withLock runs fn on a single connection holding the migrations advisory lock
+func withLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("failed to acquire migrations lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	return fn(conn)
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS community_admin;
+DROP TABLE IF EXISTS community_writer;
+DROP TABLE IF EXISTS community_subscriptions;
+DROP TABLE IF EXISTS post_likes;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS posts;
+DROP TABLE IF EXISTS communities;
+DROP TABLE IF EXISTS friendships;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS community_join_requests;
+
+ALTER TABLE community_subscriptions
+    DROP CONSTRAINT IF EXISTS community_subscriptions_user_id_community_id_key;
@@ -0,0 +1,20 @@
+-- Одна подписка на пару (пользователь, сообщество)
+DELETE FROM community_subscriptions a
+USING community_subscriptions b
+WHERE a.user_id = b.user_id
+  AND a.community_id = b.community_id
+  AND a.id > b.id;
+
+ALTER TABLE community_subscriptions
+    ADD CONSTRAINT community_subscriptions_user_id_community_id_key UNIQUE (user_id, community_id);
+
+-- Заявки на вступление в приватные сообщества
+CREATE TABLE community_join_requests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    community_id BIGINT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, community_id)
+);
+
+CREATE INDEX idx_community_join_requests_community_id ON community_join_requests(community_id);
@@ -0,0 +1,5 @@
+ALTER TABLE community_admin
+    DROP CONSTRAINT IF EXISTS community_admin_user_id_community_id_key;
+
+ALTER TABLE community_writer
+    DROP CONSTRAINT IF EXISTS community_writer_user_id_community_id_key;
@@ -0,0 +1,23 @@
+DELETE FROM community_writer a
+USING community_writer b
+WHERE a.user_id = b.user_id
+  AND a.community_id = b.community_id
+  AND a.id > b.id;
+
+DELETE FROM community_admin a
+USING community_admin b
+WHERE a.user_id = b.user_id
+  AND a.community_id = b.community_id
+  AND a.id > b.id;
+
+ALTER TABLE community_writer
+    ADD CONSTRAINT community_writer_user_id_community_id_key UNIQUE (user_id, community_id);
+
+ALTER TABLE community_admin
+    ADD CONSTRAINT community_admin_user_id_community_id_key UNIQUE (user_id, community_id);
+
+-- Создатель сообщества без админов становится его первым админом
+INSERT INTO community_admin (user_id, community_id)
+SELECT c.created_by, c.id
+FROM communities c
+WHERE NOT EXISTS (SELECT 1 FROM community_admin a WHERE a.community_id = c.id);
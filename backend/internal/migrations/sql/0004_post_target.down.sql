@@ -0,0 +1,19 @@
+-- Возвращает старую схему, где community_id постов профиля хранит ID владельца стены
+ALTER TABLE posts
+    DROP CONSTRAINT IF EXISTS posts_target_check,
+    DROP CONSTRAINT IF EXISTS posts_author_id_fkey,
+    DROP CONSTRAINT IF EXISTS posts_community_id_fkey,
+    DROP CONSTRAINT IF EXISTS posts_wall_user_id_fkey;
+
+DROP INDEX IF EXISTS idx_posts_wall_user_id;
+
+UPDATE posts
+SET community_id = wall_user_id
+WHERE target_type = 'user';
+
+ALTER TABLE posts
+    ALTER COLUMN community_id SET NOT NULL,
+    DROP COLUMN wall_user_id,
+    DROP COLUMN target_type;
+
+DROP TYPE IF EXISTS post_target;
@@ -0,0 +1,51 @@
+-- Разделяет стены пользователей и ленты сообществ в таблице posts.
+-- Раньше posts.community_id хранил ID владельца стены для постов профиля
+-- (community_id = author_id), из-за чего стена пользователя 3 и сообщество 3
+-- возвращали посты друг друга.
+--
+-- Классификация существующих строк:
+--   * community, если сообщество с таким ID существует и пост либо написан
+--     не владельцем "стены" (community_id <> author_id), либо автор является
+--     админом/редактором этого сообщества;
+--   * user во всех остальных случаях; стеной считается пользователь
+--     community_id, а если такого нет - автор.
+
+CREATE TYPE post_target AS ENUM ('user', 'community');
+
+ALTER TABLE posts
+    ADD COLUMN target_type post_target,
+    ADD COLUMN wall_user_id BIGINT;
+
+UPDATE posts p
+SET target_type = 'community'
+WHERE EXISTS (SELECT 1 FROM communities c WHERE c.id = p.community_id)
+  AND (
+      p.community_id <> p.author_id
+      OR EXISTS (SELECT 1 FROM community_admin a WHERE a.community_id = p.community_id AND a.user_id = p.author_id)
+      OR EXISTS (SELECT 1 FROM community_writer w WHERE w.community_id = p.community_id AND w.user_id = p.author_id)
+  );
+
+UPDATE posts p
+SET target_type = 'user',
+    wall_user_id = CASE
+        WHEN EXISTS (SELECT 1 FROM users u WHERE u.id = p.community_id) THEN p.community_id
+        ELSE p.author_id
+    END,
+    community_id = NULL
+WHERE target_type IS NULL;
+
+-- Посты удалённых авторов не могут удовлетворить внешнему ключу
+DELETE FROM posts p WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = p.author_id);
+
+ALTER TABLE posts
+    ALTER COLUMN target_type SET NOT NULL,
+    ALTER COLUMN community_id DROP NOT NULL,
+    ADD CONSTRAINT posts_wall_user_id_fkey FOREIGN KEY (wall_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT posts_community_id_fkey FOREIGN KEY (community_id) REFERENCES communities(id) ON DELETE CASCADE,
+    ADD CONSTRAINT posts_author_id_fkey FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
+    ADD CONSTRAINT posts_target_check CHECK (
+        (target_type = 'user' AND wall_user_id IS NOT NULL AND community_id IS NULL) OR
+        (target_type = 'community' AND community_id IS NOT NULL AND wall_user_id IS NULL)
+    );
+
+CREATE INDEX idx_posts_wall_user_id ON posts(wall_user_id);
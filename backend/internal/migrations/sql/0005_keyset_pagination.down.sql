@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_community_subscriptions_community_created_at_id;
+DROP INDEX IF EXISTS idx_comments_post_created_at_id;
+DROP INDEX IF EXISTS idx_posts_community_created_at_id;
+DROP INDEX IF EXISTS idx_posts_wall_created_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+
+ALTER TABLE community_subscriptions DROP COLUMN IF EXISTS created_at;
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,12 @@
+-- Колонки и индексы для keyset-пагинации по (created_at, id)
+ALTER TABLE users
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE community_subscriptions
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX idx_posts_wall_created_at_id ON posts(wall_user_id, created_at DESC, id DESC);
+CREATE INDEX idx_posts_community_created_at_id ON posts(community_id, created_at DESC, id DESC);
+CREATE INDEX idx_comments_post_created_at_id ON comments(post_id, created_at, id);
+CREATE INDEX idx_community_subscriptions_community_created_at_id ON community_subscriptions(community_id, created_at, id);
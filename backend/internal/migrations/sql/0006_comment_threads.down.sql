@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_comments_parent_created_at_id;
+
+-- Плоская модель не умеет хранить заглушки и ответы
+DELETE FROM comments WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE comments
+    DROP CONSTRAINT IF EXISTS comments_depth_check,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS depth,
+    DROP COLUMN IF EXISTS parent_id;
@@ -0,0 +1,14 @@
+-- Ответы на комментарии.
+-- depth = 0 у комментариев к посту, у ответа depth родителя + 1.
+-- deleted_at заполняется, когда удаляют комментарий, у которого есть ответы:
+-- строка остаётся, чтобы ветка не рассыпалась, и отдаётся как "[deleted]".
+
+ALTER TABLE comments
+    ADD COLUMN parent_id BIGINT REFERENCES comments(id) ON DELETE CASCADE,
+    ADD COLUMN depth SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD CONSTRAINT comments_depth_check CHECK (
+        (parent_id IS NULL AND depth = 0) OR (parent_id IS NOT NULL AND depth > 0)
+    );
+
+CREATE INDEX idx_comments_parent_created_at_id ON comments(parent_id, created_at, id);
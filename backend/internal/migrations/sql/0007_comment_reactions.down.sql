@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS comment_reactions;
+DROP TYPE IF EXISTS reaction_kind;
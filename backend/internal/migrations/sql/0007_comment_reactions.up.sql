@@ -0,0 +1,13 @@
+-- Фиксированный набор реакций, общий для комментариев и постов
+CREATE TYPE reaction_kind AS ENUM ('like', 'love', 'laugh', 'wow', 'sad', 'angry');
+
+-- Пользователь может поставить комментарию несколько разных реакций
+CREATE TABLE comment_reactions (
+    comment_id BIGINT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reaction reaction_kind NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (comment_id, user_id, reaction)
+);
+
+CREATE INDEX idx_comment_reactions_user_id ON comment_reactions(user_id);
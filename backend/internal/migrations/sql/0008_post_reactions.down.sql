@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_post_reactions_post_created_at;
+ALTER INDEX IF EXISTS idx_post_reactions_user_id RENAME TO idx_post_likes_user_id;
+ALTER INDEX IF EXISTS post_reactions_pkey RENAME TO post_likes_pkey;
+
+-- Любая реакция снова считается лайком
+ALTER TABLE post_reactions
+    DROP CONSTRAINT IF EXISTS post_reactions_user_id_fkey,
+    DROP COLUMN IF EXISTS reaction,
+    ALTER COLUMN created_at DROP DEFAULT;
+
+ALTER TABLE post_reactions RENAME TO post_likes;
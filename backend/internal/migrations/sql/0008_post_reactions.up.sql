@@ -0,0 +1,18 @@
+-- Лайки постов становятся реакциями: у пользователя одна реакция на пост,
+-- её можно сменить. Существующие лайки превращаются в реакцию 'like'.
+ALTER TABLE post_likes RENAME TO post_reactions;
+
+-- Лайки удалённых пользователей мешают внешнему ключу
+DELETE FROM post_reactions pr WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = pr.user_id);
+
+ALTER TABLE post_reactions
+    DROP CONSTRAINT IF EXISTS post_likes_post_id_user_id_key,
+    ADD COLUMN reaction reaction_kind NOT NULL DEFAULT 'like',
+    ALTER COLUMN created_at SET DEFAULT CURRENT_TIMESTAMP,
+    ADD CONSTRAINT post_reactions_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE post_reactions ALTER COLUMN reaction DROP DEFAULT;
+ALTER INDEX IF EXISTS post_likes_pkey RENAME TO post_reactions_pkey;
+ALTER INDEX IF EXISTS idx_post_likes_user_id RENAME TO idx_post_reactions_user_id;
+
+CREATE INDEX idx_post_reactions_post_created_at ON post_reactions(post_id, created_at DESC, user_id DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_friendships_blocked;
+
+ALTER TABLE friendships DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,6 @@
+-- Время создания связи: для блокировок - момент блокировки
+ALTER TABLE friendships
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Блокировка хранится строкой (user_id = кто заблокировал, friend_id = кого, status = 'blocked')
+CREATE INDEX idx_friendships_blocked ON friendships(friend_id, user_id) WHERE status = 'blocked';
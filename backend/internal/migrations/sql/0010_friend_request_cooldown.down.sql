@@ -0,0 +1,3 @@
+DELETE FROM friendships WHERE status = 'rejected';
+
+ALTER TABLE friendships DROP COLUMN IF EXISTS responded_at;
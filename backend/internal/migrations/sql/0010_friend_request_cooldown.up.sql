@@ -0,0 +1,4 @@
+-- Отклонённые заявки больше не удаляются: status = 'rejected' и время ответа
+-- нужны, чтобы отправитель мог повторить заявку только после паузы
+ALTER TABLE friendships
+    ADD COLUMN responded_at TIMESTAMP;
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS community_subscriptions_overlap_delete ON community_subscriptions;
+DROP TRIGGER IF EXISTS community_subscriptions_overlap_insert ON community_subscriptions;
+DROP FUNCTION IF EXISTS community_overlap_removed();
+DROP FUNCTION IF EXISTS community_overlap_added();
+DROP TABLE IF EXISTS community_overlap;
@@ -0,0 +1,82 @@
+-- Предвычисленное пересечение подписчиков для графа сообществ и рекомендаций.
+-- Пара хранится один раз: community_a < community_b.
+CREATE TABLE community_overlap (
+    community_a BIGINT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    community_b BIGINT NOT NULL REFERENCES communities(id) ON DELETE CASCADE,
+    common BIGINT NOT NULL,
+    PRIMARY KEY (community_a, community_b),
+    CHECK (community_a < community_b),
+    CHECK (common > 0)
+);
+
+CREATE INDEX idx_community_overlap_b ON community_overlap(community_b);
+
+INSERT INTO community_overlap (community_a, community_b, common)
+SELECT s1.community_id, s2.community_id, COUNT(*)
+FROM community_subscriptions s1
+JOIN community_subscriptions s2 ON s1.user_id = s2.user_id AND s1.community_id < s2.community_id
+GROUP BY s1.community_id, s2.community_id;
+
+-- Инкрементальное обновление на уровне оператора: одна команда может
+-- добавить или удалить несколько подписок одного пользователя (например,
+-- каскадное удаление пользователя), поэтому пары считаются по всем
+-- затронутым строкам сразу через transition tables.
+
+CREATE FUNCTION community_overlap_added() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO community_overlap (community_a, community_b, common)
+    SELECT s1.community_id, s2.community_id, COUNT(*)
+    FROM community_subscriptions s1
+    JOIN community_subscriptions s2 ON s1.user_id = s2.user_id AND s1.community_id < s2.community_id
+    WHERE EXISTS (
+        SELECT 1 FROM added a
+        WHERE a.user_id = s1.user_id AND a.community_id IN (s1.community_id, s2.community_id)
+    )
+    GROUP BY s1.community_id, s2.community_id
+    ON CONFLICT (community_a, community_b)
+    DO UPDATE SET common = community_overlap.common + EXCLUDED.common;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION community_overlap_removed() RETURNS trigger AS $$
+DECLARE
+    pair RECORD;
+BEGIN
+    FOR pair IN
+        WITH before AS (
+            SELECT user_id, community_id FROM community_subscriptions
+            WHERE user_id IN (SELECT user_id FROM removed)
+            UNION ALL
+            SELECT user_id, community_id FROM removed
+        )
+        SELECT b1.community_id AS a, b2.community_id AS b, COUNT(*) AS n
+        FROM before b1
+        JOIN before b2 ON b1.user_id = b2.user_id AND b1.community_id < b2.community_id
+        WHERE EXISTS (
+            SELECT 1 FROM removed r
+            WHERE r.user_id = b1.user_id AND r.community_id IN (b1.community_id, b2.community_id)
+        )
+        GROUP BY b1.community_id, b2.community_id
+    LOOP
+        DELETE FROM community_overlap
+        WHERE community_a = pair.a AND community_b = pair.b AND common <= pair.n;
+
+        UPDATE community_overlap SET common = common - pair.n
+        WHERE community_a = pair.a AND community_b = pair.b;
+    END LOOP;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER community_subscriptions_overlap_insert
+AFTER INSERT ON community_subscriptions
+REFERENCING NEW TABLE AS added
+FOR EACH STATEMENT EXECUTE FUNCTION community_overlap_added();
+
+CREATE TRIGGER community_subscriptions_overlap_delete
+AFTER DELETE ON community_subscriptions
+REFERENCING OLD TABLE AS removed
+FOR EACH STATEMENT EXECUTE FUNCTION community_overlap_removed();
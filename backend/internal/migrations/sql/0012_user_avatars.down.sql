@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS avatar_type,
+    DROP COLUMN IF EXISTS avatar_key;
+
+ALTER TABLE users ADD COLUMN avatar BYTEA;
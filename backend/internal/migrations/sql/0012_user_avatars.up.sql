@@ -0,0 +1,7 @@
+-- Аватары лежат в хранилище файлов (см. internal/storage), в таблице - только ссылка.
+-- Столбец avatar BYTEA никогда не заполнялся.
+ALTER TABLE users DROP COLUMN IF EXISTS avatar;
+
+ALTER TABLE users
+    ADD COLUMN avatar_key VARCHAR(255),
+    ADD COLUMN avatar_type VARCHAR(32);
@@ -0,0 +1,23 @@
+-- Загруженные изображения. Пока файл не прикреплён к посту, он "сирота"
+-- и удаляется фоновой очисткой после льготного периода.
+CREATE TABLE media (
+    id BIGSERIAL PRIMARY KEY,
+    owner_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    storage_key VARCHAR(255) NOT NULL UNIQUE,
+    content_type VARCHAR(64) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_media_owner ON media(owner_id);
+CREATE INDEX idx_media_created_at ON media(created_at);
+
+-- Вложения поста; файл принадлежит не больше чем одному посту
+CREATE TABLE post_media (
+    post_id BIGINT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    media_id BIGINT NOT NULL UNIQUE REFERENCES media(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    PRIMARY KEY (post_id, media_id)
+);
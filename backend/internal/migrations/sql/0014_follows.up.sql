@@ -0,0 +1,11 @@
+-- Односторонние подписки на пользователей, независимо от взаимной дружбы
+CREATE TABLE follows (
+    follower_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    followee_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, followee_id),
+    CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX idx_follows_followee_created_at ON follows (followee_id, created_at DESC, follower_id DESC);
+CREATE INDEX idx_follows_follower_created_at ON follows (follower_id, created_at DESC, followee_id DESC);
@@ -0,0 +1 @@
+ALTER TABLE communities ALTER COLUMN created_at DROP NOT NULL;
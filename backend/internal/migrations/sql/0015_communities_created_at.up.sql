@@ -0,0 +1,10 @@
+-- created_at сообществ был nullable, а код читает его в time.Time.
+-- Пустые значения заполняем датой первого поста, иначе текущим временем.
+UPDATE communities c
+SET created_at = COALESCE(
+    (SELECT MIN(p.created_at) FROM posts p WHERE p.community_id = c.id),
+    CURRENT_TIMESTAMP
+)
+WHERE c.created_at IS NULL;
+
+ALTER TABLE communities ALTER COLUMN created_at SET NOT NULL;
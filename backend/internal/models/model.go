@@ -1,17 +1,20 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"time"
+)
 
 // User - пользователь
 type User struct {
-	ID           int64  `json:"id"         db:"id"`
-	Username     string `json:"username"   db:"username"`
-	Email        string `json:"email"      db:"email"`
-	PasswordHash string `json:"-"          db:"password_hash"`
-	Salt         []byte `json:"-"          db:"salt"`
-	Bio          string `json:"bio"        db:"bio"`
-	Avatar       []byte `json:"-"          db:"avatar"`
-	AvatarURL    string `json:"avatar_url" db:"avatar_url"`
+	ID           int64  `json:"id"              db:"id"`
+	Username     string `json:"username"        db:"username"`
+	Email        string `json:"email,omitempty" db:"email"`
+	PasswordHash string `json:"-"               db:"password_hash"`
+	Salt         []byte `json:"-"               db:"salt"`
+	Bio          string `json:"bio"             db:"bio"`
+	AvatarURL    string `json:"avatar_url"      db:"avatar_url"`
 }
 
 // Friendship - отношение дружбы
@@ -19,7 +22,7 @@ type Friendship struct {
 	ID       int64  `json:"id"        db:"id"`
 	UserID   int64  `json:"user_id"   db:"user_id"`
 	FriendID int64  `json:"friend_id" db:"friend_id"`
-	Status   string `json:"status"    db:"status"` // pending, accepted, blocked
+	Status   string `json:"status"    db:"status"` // pending, accepted, rejected, blocked
 }
 
 // Community - сообщество
@@ -30,8 +33,17 @@ type Community struct {
 	IsPrivate   bool      `json:"is_private"  db:"is_private"`
 	CreatedBy   int64     `json:"created_by"  db:"created_by"`
 	CreatedAt   time.Time `json:"created_at"  db:"created_at"`
-	Admins      []int64   `json:"admins"`
-	Writers     []int64   `json:"writers"`
+	Admins      []int64   `json:"admins,omitempty"`
+	Writers     []int64   `json:"writers,omitempty"`
+}
+
+// PostReaction - реакция пользователя на пост
+type PostReaction struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	Reaction  string    `json:"reaction"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // CommunitySubscription - подписка на сообщество
@@ -41,19 +53,60 @@ type CommunitySubscription struct {
 	CommunityID int64 `json:"community_id" db:"community_id"`
 }
 
-// Post - пост в сообществе
+// Post - пост на стене пользователя или в сообществе
 type Post struct {
-	ID          int64     `json:"id"                  db:"id"`
-	Title       string    `json:"title"               db:"title"`
-	Text        string    `json:"text"                db:"text"`
-	PicURL      string    `json:"pic_url"             db:"pic_url"`
-	CommunityID int64     `json:"community_id"        db:"community_id"`
-	AuthorID    int64     `json:"author_id"           db:"author_id"`
-	CreatedAt   time.Time `json:"created_at"          db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"          db:"updated_at"`
+	ID          int64     `json:"id"                     db:"id"`
+	Title       string    `json:"title"                  db:"title"`
+	Text        string    `json:"text"                   db:"text"`
+	PicURL      string    `json:"pic_url"                db:"pic_url"`
+	TargetType  string    `json:"target_type"            db:"target_type"`
+	WallUserID  int64     `json:"wall_user_id,omitempty" db:"wall_user_id"`
+	CommunityID int64     `json:"community_id,omitempty" db:"community_id"`
+	AuthorID    int64     `json:"author_id"              db:"author_id"`
+	CreatedAt   time.Time `json:"created_at"             db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"             db:"updated_at"`
+	// Агрегаты (pg.LoadPostStats); like_count - реакции любого вида
+	LikeCount    int64            `json:"like_count"`
+	CommentCount int64            `json:"comment_count"`
+	LikedByMe    bool             `json:"liked_by_me"`
+	Reactions    map[string]int64 `json:"reactions"`
+	MyReaction   string           `json:"my_reaction,omitempty"`
 	// Загружаемые отношения
 	Author    *User      `json:"author,omitempty"`
 	Community *Community `json:"community,omitempty"`
+	Media     []*Media   `json:"media"` // pg.LoadPostMedia
+	// MediaIDs - загруженные файлы, которые pg.CreatePost прикрепит к посту
+	MediaIDs []int64 `json:"-"`
+}
+
+// Post targets
+const (
+	PostTargetUser      = "user"
+	PostTargetCommunity = "community"
+)
+
+// PostTarget - где опубликован пост: стена пользователя или лента сообщества
+type PostTarget struct {
+	Type string
+	ID   int64
+}
+
+// UserWall returns the target of posts on a user's wall
+func UserWall(userID int64) PostTarget {
+	return PostTarget{Type: PostTargetUser, ID: userID}
+}
+
+// CommunityFeed returns the target of posts in a community
+func CommunityFeed(communityID int64) PostTarget {
+	return PostTarget{Type: PostTargetCommunity, ID: communityID}
+}
+
+// Target returns where the post is published
+func (p *Post) Target() PostTarget {
+	if p.TargetType == PostTargetCommunity {
+		return CommunityFeed(p.CommunityID)
+	}
+	return UserWall(p.WallUserID)
 }
 
 // Roles
@@ -67,6 +120,7 @@ const (
 const (
 	FriendshipPending  = "pending"
 	FriendshipAccepted = "accepted"
+	FriendshipRejected = "rejected"
 	FriendshipBlocked  = "blocked"
 )
 
@@ -74,8 +128,92 @@ const (
 type Comment struct {
 	ID        int64     `json:"id"`
 	PostID    int64     `json:"post_id"`
+	ParentID  *int64    `json:"parent_id"` // nil у комментария к самому посту
+	Depth     int       `json:"depth"`
 	UserID    int64     `json:"user_id"`
 	Username  string    `json:"username"`
 	Content   string    `json:"content"`
+	Deleted   bool      `json:"deleted,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	// Реакции: количество по видам и реакции текущего пользователя
+	Reactions   map[string]int64 `json:"reactions"`
+	MyReactions []string         `json:"my_reactions,omitempty"`
+	// Ответы (только в режиме дерева)
+	ReplyCount        int64      `json:"reply_count"`
+	Replies           []*Comment `json:"replies,omitempty"`
+	RepliesNextCursor *string    `json:"replies_next_cursor,omitempty"`
+}
+
+// Reaction kinds (enum reaction_kind)
+const (
+	ReactionLike  = "like"
+	ReactionLove  = "love"
+	ReactionLaugh = "laugh"
+	ReactionWow   = "wow"
+	ReactionSad   = "sad"
+	ReactionAngry = "angry"
+)
+
+// ReactionKinds lists the supported reactions in display order
+var ReactionKinds = []string{
+	ReactionLike,
+	ReactionLove,
+	ReactionLaugh,
+	ReactionWow,
+	ReactionSad,
+	ReactionAngry,
+}
+
+// IsValidReaction reports whether kind is one of ReactionKinds
+func IsValidReaction(kind string) bool {
+	return slices.Contains(ReactionKinds, kind)
+}
+
+// MaxCommentDepth - максимальная глубина ответа (0 - комментарий к посту)
+const MaxCommentDepth = 5
+
+// DeletedCommentContent заменяет текст удалённого комментария с ответами
+const DeletedCommentContent = "[deleted]"
+
+// Media - загруженное изображение; файлы вариантов лежат в хранилище под
+// StorageKey/<variant>.<ext>
+type Media struct {
+	ID          int64             `json:"id"           db:"id"`
+	OwnerID     int64             `json:"owner_id"     db:"owner_id"`
+	StorageKey  string            `json:"-"            db:"storage_key"`
+	ContentType string            `json:"content_type" db:"content_type"`
+	Width       int               `json:"width"        db:"width"`
+	Height      int               `json:"height"       db:"height"`
+	SizeBytes   int64             `json:"size_bytes"   db:"size_bytes"`
+	CreatedAt   time.Time         `json:"created_at"   db:"created_at"`
+	PostID      int64             `json:"post_id,omitempty"`
+	Variants    map[string]string `json:"variants"`
+}
+
+// MediaVariants - наибольшая сторона каждого варианта в пикселях;
+// "full" - исходник без метаданных
+var MediaVariants = map[string]int{
+	"full":   2048,
+	"large":  1280,
+	"medium": 640,
+	"small":  320,
+}
+
+// DefaultMediaVariant отдаётся, если вариант не указан
+const DefaultMediaVariant = "large"
+
+// MaxPostMedia - сколько вложений можно прикрепить к одному посту
+const MaxPostMedia = 10
+
+// MediaURL is the public address of one variant of a media file
+func MediaURL(mediaID int64, variant string) string {
+	return fmt.Sprintf("/media/%d?variant=%s", mediaID, variant)
+}
+
+// SetVariantURLs fills Variants with the URLs of every variant
+func (m *Media) SetVariantURLs() {
+	m.Variants = make(map[string]string, len(MediaVariants))
+	for variant := range MediaVariants {
+		m.Variants[variant] = MediaURL(m.ID, variant)
+	}
 }
@@ -0,0 +1,59 @@
+package pagination
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"main/internal/pg"
+)
+
+// MaxLimit - максимальный размер страницы для всех списков
+const MaxLimit = 100
+
+// FromQuery reads ?limit=, ?cursor= and ?total=1 query parameters shared by
+// all list endpoints. An invalid or out-of-range limit falls back to
+// defaultLimit. Writes a 400 response and returns false on a malformed cursor.
+func FromQuery(c *gin.Context, defaultLimit int) (pg.PageOptions, bool) {
+	opts := pg.PageOptions{Limit: defaultLimit}
+
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 &&
+			parsed <= MaxLimit {
+			opts.Limit = parsed
+		}
+	}
+
+	after, err := pg.DecodeCursor(c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return opts, false
+	}
+	opts.After = after
+
+	opts.WithTotal, _ = strconv.ParseBool(c.Query("total"))
+
+	return opts, true
+}
+
+// Response builds a list response: items under key plus next_cursor, limit
+// and, when requested, total
+func Response(key string, items any, opts pg.PageOptions, info pg.PageInfo) gin.H {
+	var nextCursor *string
+	if info.NextCursor != nil {
+		encoded := info.NextCursor.Encode()
+		nextCursor = &encoded
+	}
+
+	resp := gin.H{
+		key:           items,
+		"next_cursor": nextCursor,
+		"limit":       opts.Limit,
+	}
+	if info.Total != nil {
+		resp["total"] = *info.Total
+	}
+
+	return resp
+}
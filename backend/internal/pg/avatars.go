@@ -0,0 +1,80 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var ErrNoAvatar = errors.New("user has no avatar")
+
+// Avatar points at a set of avatar thumbnails in storage.
+// Key - общий префикс ключей, миниатюры лежат под Key/<size>.<ext>
+type Avatar struct {
+	Key         string
+	ContentType string
+}
+
+// GetUserAvatar returns ErrUserNotFound for unknown users and ErrNoAvatar
+// if the user never uploaded one
+func GetUserAvatar(ctx context.Context, userID int64) (*Avatar, error) {
+	var key, contentType sql.NullString
+
+	err := DB.QueryRowContext(ctx,
+		`SELECT avatar_key, avatar_type FROM users WHERE id = $1`,
+		userID,
+	).Scan(&key, &contentType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch avatar: %w", err)
+	}
+
+	if !key.Valid {
+		return nil, ErrNoAvatar
+	}
+
+	return &Avatar{Key: key.String, ContentType: contentType.String}, nil
+}
+
+// SetUserAvatar points the user at a new avatar and sets avatar_url to the
+// URL that serves it. Возвращает предыдущий аватар (или nil), чтобы
+// вызывающий удалил его файлы.
+func SetUserAvatar(ctx context.Context, userID int64, avatar Avatar, url string) (*Avatar, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldKey, oldType sql.NullString
+	err = tx.QueryRowContext(ctx,
+		`SELECT avatar_key, avatar_type FROM users WHERE id = $1 FOR UPDATE`,
+		userID,
+	).Scan(&oldKey, &oldType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch avatar: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE users SET avatar_key = $1, avatar_type = $2, avatar_url = $3 WHERE id = $4`,
+		avatar.Key, avatar.ContentType, url, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update avatar: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if !oldKey.Valid {
+		return nil, nil
+	}
+	return &Avatar{Key: oldKey.String, ContentType: oldType.String}, nil
+}
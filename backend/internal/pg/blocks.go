@@ -0,0 +1,184 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrBlocked        = errors.New("user is blocked")
+	ErrAlreadyBlocked = errors.New("user is already blocked")
+	ErrNotBlocked     = errors.New("user is not blocked")
+)
+
+// BlockedUser - запись в списке блокировок
+type BlockedUser struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
+
+// BlockUser makes blockerID block userID. Any friendship, pending request or
+// follow between the two is removed; a block the other user placed stays in
+// place.
+func BlockUser(ctx context.Context, blockerID, userID int64) error {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM friendships
+		WHERE status <> $3
+		AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+	`, blockerID, userID, models.FriendshipBlocked)
+	if err != nil {
+		return fmt.Errorf("failed to remove friendship: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM follows
+		WHERE (follower_id = $1 AND followee_id = $2) OR (follower_id = $2 AND followee_id = $1)
+	`, blockerID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove follows: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO friendships (user_id, friend_id, status) VALUES ($1, $2, $3)`,
+		blockerID, userID, models.FriendshipBlocked,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return ErrAlreadyBlocked
+			case "23503":
+				return ErrUserNotFound
+			}
+		}
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// UnblockUser removes a block placed by blockerID
+func UnblockUser(ctx context.Context, blockerID, userID int64) error {
+	result, err := DB.ExecContext(ctx,
+		`DELETE FROM friendships WHERE user_id = $1 AND friend_id = $2 AND status = $3`,
+		blockerID, userID, models.FriendshipBlocked,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotBlocked
+	}
+
+	return nil
+}
+
+// HasBlocked reports whether blockerID has blocked userID
+func HasBlocked(ctx context.Context, blockerID, userID int64) (bool, error) {
+	var blocked bool
+	err := DB.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendships
+			WHERE user_id = $1 AND friend_id = $2 AND status = $3
+		)
+	`, blockerID, userID, models.FriendshipBlocked).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return blocked, nil
+}
+
+// IsBlockedEither reports whether either of the two users blocked the other
+func IsBlockedEither(ctx context.Context, userID1, userID2 int64) (bool, error) {
+	var blocked bool
+	err := DB.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendships
+			WHERE status = $3
+			AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		)
+	`, userID1, userID2, models.FriendshipBlocked).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return blocked, nil
+}
+
+// GetBlockedUsers lists users blocked by blockerID, most recent first
+func GetBlockedUsers(ctx context.Context, blockerID int64, opts PageOptions) ([]BlockedUser, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts,
+		`SELECT COUNT(*) FROM friendships WHERE user_id = $1 AND status = $2`,
+		blockerID, models.FriendshipBlocked,
+	)
+	if err != nil {
+		return nil, info, err
+	}
+
+	const query = `
+		SELECT u.id, u.username, COALESCE(u.avatar_url, ''), f.created_at, f.id
+		FROM friendships f
+		JOIN users u ON u.id = f.friend_id
+		WHERE f.user_id = $1 AND f.status = $2
+		AND ($3::timestamp IS NULL OR (f.created_at, f.id) < ($3::timestamp, $4::bigint))
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $5
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, blockerID, models.FriendshipBlocked, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch blocked users: %w", err)
+	}
+	defer rows.Close()
+
+	type blockRow struct {
+		user BlockedUser
+		pos  Cursor
+	}
+
+	page := make([]blockRow, 0, opts.Limit)
+	for rows.Next() {
+		var r blockRow
+		if err := rows.Scan(&r.user.ID, &r.user.Username, &r.user.AvatarURL, &r.pos.CreatedAt, &r.pos.ID); err != nil {
+			return nil, info, fmt.Errorf("failed to scan blocked user: %w", err)
+		}
+		r.user.BlockedAt = r.pos.CreatedAt
+		page = append(page, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	page, info.NextCursor = trimPage(page, opts.Limit, func(r blockRow) Cursor { return r.pos })
+
+	blocked := make([]BlockedUser, len(page))
+	for i, r := range page {
+		blocked[i] = r.user
+	}
+
+	return blocked, info, nil
+}
@@ -0,0 +1,139 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrAlreadyReacted = errors.New("already reacted")
+	ErrNotReacted     = errors.New("not reacted")
+)
+
+// AddCommentReaction puts a reaction on a comment.
+// Returns ErrCommentNotFound for missing or deleted comments and
+// ErrAlreadyReacted if the user already left this reaction.
+func AddCommentReaction(ctx context.Context, commentID, userID int64, reaction string) error {
+	var exists bool
+	err := DB.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM comments WHERE id = $1 AND deleted_at IS NULL)`,
+		commentID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check comment: %w", err)
+	}
+	if !exists {
+		return ErrCommentNotFound
+	}
+
+	const query = `
+		INSERT INTO comment_reactions (comment_id, user_id, reaction)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err = DB.ExecContext(ctx, query, commentID, userID, reaction)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return ErrAlreadyReacted
+			case "23503":
+				// комментарий удалили между проверкой и вставкой
+				return ErrCommentNotFound
+			}
+		}
+		return fmt.Errorf("failed to add comment reaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveCommentReaction removes a reaction the user left on a comment
+func RemoveCommentReaction(ctx context.Context, commentID, userID int64, reaction string) error {
+	const query = `
+		DELETE FROM comment_reactions
+		WHERE comment_id = $1 AND user_id = $2 AND reaction = $3
+	`
+
+	result, err := DB.ExecContext(ctx, query, commentID, userID, reaction)
+	if err != nil {
+		return fmt.Errorf("failed to remove comment reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNotReacted
+	}
+
+	return nil
+}
+
+// loadCommentReactions fills Reactions and MyReactions of the given comments
+// with a single query. viewerID 0 means an anonymous viewer.
+func loadCommentReactions(ctx context.Context, comments []*models.Comment, viewerID int64) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Comment, len(comments))
+	ids := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		comment.Reactions = map[string]int64{}
+		comment.MyReactions = nil
+		byID[comment.ID] = comment
+		ids = append(ids, comment.ID)
+	}
+
+	const query = `
+		SELECT comment_id, reaction, COUNT(*), BOOL_OR(user_id = $2)
+		FROM comment_reactions
+		WHERE comment_id = ANY($1)
+		GROUP BY comment_id, reaction
+		ORDER BY comment_id, reaction
+	`
+
+	rows, err := DB.QueryContext(ctx, query, pq.Array(ids), viewerID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch comment reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID, count int64
+		var reaction string
+		var mine sql.NullBool
+
+		if err := rows.Scan(&commentID, &reaction, &count, &mine); err != nil {
+			return fmt.Errorf("failed to scan comment reaction: %w", err)
+		}
+
+		comment := byID[commentID]
+		comment.Reactions[reaction] = count
+		if mine.Bool {
+			comment.MyReactions = append(comment.MyReactions, reaction)
+		}
+	}
+
+	return rows.Err()
+}
+
+// collectComments flattens threads into a single slice for batch loading
+func collectComments(comments []*models.Comment) []*models.Comment {
+	all := make([]*models.Comment, 0, len(comments))
+	for _, comment := range comments {
+		all = append(all, comment)
+		all = append(all, collectComments(comment.Replies)...)
+	}
+	return all
+}
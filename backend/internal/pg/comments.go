@@ -8,18 +8,99 @@ import (
 	"log"
 	"main/internal/models"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrParentNotFound  = errors.New("parent comment not found")
+	ErrMaxDepth        = errors.New("reply depth limit reached")
 )
 
-// CreateComment inserts a new comment into the database
+// commentColumns - колонки для scanComment, таблица comments под алиасом c
+const commentColumns = `
+	c.id, c.post_id, c.parent_id, c.depth, c.user_id, c.username, c.content,
+	c.deleted_at IS NOT NULL, c.created_at,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_id = c.id)
+`
+
+// scanComment reads a row selected with commentColumns.
+// Deleted comments keep their place in the thread but lose author and text.
+func scanComment(row rowScanner, comment *models.Comment) error {
+	var parentID sql.NullInt64
+
+	err := row.Scan(
+		&comment.ID,
+		&comment.PostID,
+		&parentID,
+		&comment.Depth,
+		&comment.UserID,
+		&comment.Username,
+		&comment.Content,
+		&comment.Deleted,
+		&comment.CreatedAt,
+		&comment.ReplyCount,
+	)
+	if err != nil {
+		return err
+	}
+
+	if parentID.Valid {
+		comment.ParentID = &parentID.Int64
+	}
+	if comment.Deleted {
+		comment.UserID = 0
+		comment.Username = ""
+		comment.Content = models.DeletedCommentContent
+	}
+
+	return nil
+}
+
+// CreateComment inserts a new comment into the database.
+// For a reply (comment.ParentID set) the parent must belong to the same post,
+// must not be deleted, and the reply must fit into models.MaxCommentDepth.
 func CreateComment(ctx context.Context, comment *models.Comment) error {
+	comment.Depth = 0
+
+	if comment.ParentID != nil {
+		var parentPostID int64
+		var parentDepth int
+		var parentDeleted bool
+
+		err := DB.QueryRowContext(ctx,
+			`SELECT post_id, depth, deleted_at IS NOT NULL FROM comments WHERE id = $1`,
+			*comment.ParentID,
+		).Scan(&parentPostID, &parentDepth, &parentDeleted)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrParentNotFound
+			}
+			log.Printf("Error getting parent comment: %v", err)
+			return err
+		}
+
+		if parentPostID != comment.PostID || parentDeleted {
+			return ErrParentNotFound
+		}
+		if parentDepth+1 > models.MaxCommentDepth {
+			return ErrMaxDepth
+		}
+
+		comment.Depth = parentDepth + 1
+	}
+
 	query := `
-		INSERT INTO comments (post_id, user_id, username, content, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO comments (post_id, parent_id, depth, user_id, username, content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at
 	`
 
 	err := DB.QueryRowContext(ctx, query,
 		comment.PostID,
+		comment.ParentID,
+		comment.Depth,
 		comment.UserID,
 		comment.Username,
 		comment.Content,
@@ -34,80 +115,70 @@ func CreateComment(ctx context.Context, comment *models.Comment) error {
 	return nil
 }
 
-// GetCommentByID retrieves a single comment by ID
-func GetCommentByID(ctx context.Context, commentID int64) (*models.Comment, error) {
-	query := `
-		SELECT id, post_id, user_id, username, content, created_at
-		FROM comments
-		WHERE id = $1
-	`
+// GetCommentByID retrieves a single comment by ID with its reactions as
+// seen by viewerID (0 - anonymous).
+// Deleted placeholders are returned with Deleted set.
+func GetCommentByID(ctx context.Context, commentID, viewerID int64) (*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments c WHERE c.id = $1`
 
 	var comment models.Comment
 
-	err := DB.QueryRowContext(ctx, query, commentID).Scan(
-		&comment.ID,
-		&comment.PostID,
-		&comment.UserID,
-		&comment.Username,
-		&comment.Content,
-		&comment.CreatedAt,
-	)
-
+	err := scanComment(DB.QueryRowContext(ctx, query, commentID), &comment)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("comment not found")
+			return nil, ErrCommentNotFound
 		}
 		log.Printf("Error getting comment: %v", err)
 		return nil, err
 	}
 
+	if err := loadCommentReactions(ctx, []*models.Comment{&comment}, viewerID); err != nil {
+		log.Printf("Error loading comment reactions: %v", err)
+		return nil, err
+	}
+
 	return &comment, nil
 }
 
-// GetCommentsByPostID retrieves all comments for a specific post with pagination
-func GetCommentsByPostID(ctx context.Context, postID int64, limit, offset int) ([]models.Comment, int, error) {
-	// Get total count
-	countQuery := `SELECT COUNT(*) FROM comments WHERE post_id = $1`
-	var total int
-	err := DB.QueryRowContext(ctx, countQuery, postID).Scan(&total)
+// GetCommentsByPostID retrieves all comments of a post, replies included,
+// as a flat list, oldest first, one keyset page at a time.
+// Reactions are loaded as seen by viewerID (0 - anonymous).
+func GetCommentsByPostID(ctx context.Context, postID, viewerID int64, opts PageOptions) ([]models.Comment, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts, `SELECT COUNT(*) FROM comments WHERE post_id = $1`, postID)
 	if err != nil {
 		log.Printf("Error counting comments: %v", err)
-		return nil, 0, err
+		return nil, info, err
 	}
 
-	// Get paginated results
 	query := `
-		SELECT id, post_id, user_id, username, content, created_at
-		FROM comments
-		WHERE post_id = $1
-		ORDER BY created_at ASC
-		LIMIT $2 OFFSET $3
+		SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE c.post_id = $1
+		AND ($2::timestamp IS NULL OR (c.created_at, c.id) > ($2::timestamp, $3::bigint))
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT $4
 	`
 
-	rows, err := DB.QueryContext(ctx, query, postID, limit, offset)
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, postID, afterTime, afterID, opts.Limit+1)
 	if err != nil {
 		log.Printf("Error querying comments: %v", err)
-		return nil, 0, err
+		return nil, info, err
 	}
 	defer rows.Close()
 
-	var comments []models.Comment
+	comments := make([]models.Comment, 0, opts.Limit)
 
 	for rows.Next() {
 		var comment models.Comment
 
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Username,
-			&comment.Content,
-			&comment.CreatedAt,
-		)
-
-		if err != nil {
+		if err := scanComment(rows, &comment); err != nil {
 			log.Printf("Error scanning comment: %v", err)
-			return nil, 0, err
+			return nil, info, err
 		}
 
 		comments = append(comments, comment)
@@ -115,14 +186,169 @@ func GetCommentsByPostID(ctx context.Context, postID int64, limit, offset int) (
 
 	if err := rows.Err(); err != nil {
 		log.Printf("Error iterating comments: %v", err)
-		return nil, 0, err
+		return nil, info, err
+	}
+
+	comments, info.NextCursor = trimPage(comments, opts.Limit, func(c models.Comment) Cursor {
+		return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	})
+
+	page := make([]*models.Comment, len(comments))
+	for i := range comments {
+		page[i] = &comments[i]
+	}
+	if err := loadCommentReactions(ctx, page, viewerID); err != nil {
+		log.Printf("Error loading comment reactions: %v", err)
+		return nil, info, err
+	}
+
+	return comments, info, nil
+}
+
+// GetCommentTree returns a page of threads of a post, oldest first.
+// parentID nil pages over top-level comments, otherwise over direct replies
+// of that comment. Every returned comment carries up to repliesLimit of its
+// own replies, recursively down to models.MaxCommentDepth; a thread with
+// more replies gets RepliesNextCursor to continue with parentID set to it.
+// Reactions are loaded as seen by viewerID (0 - anonymous).
+func GetCommentTree(
+	ctx context.Context,
+	postID int64,
+	parentID *int64,
+	viewerID int64,
+	opts PageOptions,
+	repliesLimit int,
+) ([]*models.Comment, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts, `
+		SELECT COUNT(*) FROM comments
+		WHERE post_id = $1 AND parent_id IS NOT DISTINCT FROM $2::bigint
+	`, postID, parentID)
+	if err != nil {
+		log.Printf("Error counting comment threads: %v", err)
+		return nil, info, err
+	}
+
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments c
+		WHERE c.post_id = $1 AND c.parent_id IS NOT DISTINCT FROM $2::bigint
+		AND ($3::timestamp IS NULL OR (c.created_at, c.id) > ($3::timestamp, $4::bigint))
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT $5
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, postID, parentID, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		log.Printf("Error querying comment threads: %v", err)
+		return nil, info, err
+	}
+
+	roots := make([]*models.Comment, 0, opts.Limit)
+	for rows.Next() {
+		comment := &models.Comment{}
+		if err := scanComment(rows, comment); err != nil {
+			rows.Close()
+			log.Printf("Error scanning comment: %v", err)
+			return nil, info, err
+		}
+		roots = append(roots, comment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating comments: %v", err)
+		return nil, info, err
+	}
+
+	roots, info.NextCursor = trimPage(roots, opts.Limit, commentCursor)
+
+	if err := loadReplies(ctx, roots, repliesLimit); err != nil {
+		return nil, info, err
+	}
+
+	if err := loadCommentReactions(ctx, collectComments(roots), viewerID); err != nil {
+		log.Printf("Error loading comment reactions: %v", err)
+		return nil, info, err
 	}
 
-	if comments == nil {
-		comments = []models.Comment{}
+	return roots, info, nil
+}
+
+// loadReplies fills Replies level by level: one query per depth, taking the
+// first repliesLimit replies of every parent with a window function
+func loadReplies(ctx context.Context, level []*models.Comment, repliesLimit int) error {
+	const query = `
+		SELECT ` + commentColumns + `
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (
+				PARTITION BY parent_id ORDER BY created_at ASC, id ASC
+			) AS rn
+			FROM comments
+			WHERE parent_id = ANY($1)
+		) c
+		WHERE c.rn <= $2
+		ORDER BY c.parent_id, c.created_at ASC, c.id ASC
+	`
+
+	for len(level) > 0 && repliesLimit > 0 {
+		byID := make(map[int64]*models.Comment, len(level))
+		ids := make([]int64, 0, len(level))
+		for _, comment := range level {
+			if comment.ReplyCount == 0 {
+				continue
+			}
+			byID[comment.ID] = comment
+			ids = append(ids, comment.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		rows, err := DB.QueryContext(ctx, query, pq.Array(ids), repliesLimit+1)
+		if err != nil {
+			log.Printf("Error querying replies: %v", err)
+			return err
+		}
+
+		var next []*models.Comment
+		for rows.Next() {
+			reply := &models.Comment{}
+			if err := scanComment(rows, reply); err != nil {
+				rows.Close()
+				log.Printf("Error scanning reply: %v", err)
+				return err
+			}
+			parent := byID[*reply.ParentID]
+			parent.Replies = append(parent.Replies, reply)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Printf("Error iterating replies: %v", err)
+			return err
+		}
+
+		for _, parent := range byID {
+			var cursor *Cursor
+			parent.Replies, cursor = trimPage(parent.Replies, repliesLimit, commentCursor)
+			if cursor != nil {
+				encoded := cursor.Encode()
+				parent.RepliesNextCursor = &encoded
+			}
+			next = append(next, parent.Replies...)
+		}
+
+		level = next
 	}
 
-	return comments, total, nil
+	return nil
+}
+
+func commentCursor(c *models.Comment) Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
 }
 
 // UpdateComment updates an existing comment
@@ -130,7 +356,7 @@ func UpdateComment(ctx context.Context, comment *models.Comment) error {
 	query := `
 		UPDATE comments
 		SET content = $1
-		WHERE id = $2
+		WHERE id = $2 AND deleted_at IS NULL
 	`
 
 	result, err := DB.ExecContext(ctx, query, comment.Content, comment.ID)
@@ -146,38 +372,83 @@ func UpdateComment(ctx context.Context, comment *models.Comment) error {
 	}
 
 	if rowsAffected == 0 {
-		return errors.New("comment not found")
+		return ErrCommentNotFound
 	}
 
 	return nil
 }
 
-// DeleteComment deletes a comment by ID
+// DeleteComment deletes a comment by ID.
+// A comment that has replies is turned into a "[deleted]" placeholder so the
+// thread stays intact. Removing the last reply of a placeholder removes the
+// placeholder too, up the thread.
 func DeleteComment(ctx context.Context, commentID int64) error {
-	query := `DELETE FROM comments WHERE id = $1`
-
-	result, err := DB.ExecContext(ctx, query, commentID)
+	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("Error deleting comment: %v", err)
+		log.Printf("Error starting transaction: %v", err)
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	var parentID sql.NullInt64
+	var hasReplies bool
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT parent_id, EXISTS(SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+		FROM comments c
+		WHERE c.id = $1 AND c.deleted_at IS NULL
+		FOR UPDATE
+	`, commentID).Scan(&parentID, &hasReplies)
 	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCommentNotFound
+		}
+		log.Printf("Error getting comment: %v", err)
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return errors.New("comment not found")
+	if hasReplies {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE comments SET content = '', deleted_at = $1 WHERE id = $2`,
+			time.Now().UTC(), commentID,
+		)
+		if err != nil {
+			log.Printf("Error marking comment deleted: %v", err)
+			return err
+		}
+		return tx.Commit()
 	}
 
-	return nil
+	if _, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, commentID); err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		return err
+	}
+
+	// Убираем опустевшие заглушки вверх по ветке
+	for parentID.Valid {
+		var next sql.NullInt64
+		err = tx.QueryRowContext(ctx, `
+			DELETE FROM comments c
+			WHERE c.id = $1 AND c.deleted_at IS NOT NULL
+			AND NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_id = c.id)
+			RETURNING c.parent_id
+		`, parentID.Int64).Scan(&next)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			log.Printf("Error deleting comment placeholder: %v", err)
+			return err
+		}
+		parentID = next
+	}
+
+	return tx.Commit()
 }
 
-// GetCommentCount returns the number of comments for a post
+// GetCommentCount returns the number of visible comments for a post
 func GetCommentCount(ctx context.Context, postID int64) (int, error) {
-	query := `SELECT COUNT(*) FROM comments WHERE post_id = $1`
+	query := `SELECT COUNT(*) FROM comments WHERE post_id = $1 AND deleted_at IS NULL`
 
 	var count int
 	err := DB.QueryRowContext(ctx, query, postID).Scan(&count)
@@ -0,0 +1,102 @@
+package pg
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor - позиция в списке, упорядоченном по (created_at, id).
+// Клиенту отдаётся только непрозрачная строка из Encode.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode.
+// An empty string means "from the beginning" and returns nil.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	nanosStr, idStr, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// cursorArgs returns query arguments for
+// "($n::timestamp IS NULL OR (created_at, id) < ($n::timestamp, $n+1::bigint))",
+// NULLs when there is no cursor
+func cursorArgs(c *Cursor) (any, any) {
+	if c == nil {
+		return nil, nil
+	}
+	return c.CreatedAt, c.ID
+}
+
+// PageOptions - параметры keyset-пагинации списка
+type PageOptions struct {
+	After     *Cursor // продолжить после этой позиции, nil - с начала
+	Limit     int
+	WithTotal bool // дополнительно посчитать общее количество (отдельный COUNT)
+}
+
+// PageInfo - сведения о полученной странице
+type PageInfo struct {
+	NextCursor *Cursor // nil, если это последняя страница
+	Total      *int64  // заполняется только при PageOptions.WithTotal
+}
+
+// trimPage cuts the extra row requested with LIMIT limit+1 and
+// returns the cursor of the last kept item if there are more rows
+func trimPage[T any](items []T, limit int, cursorOf func(T) Cursor) ([]T, *Cursor) {
+	if len(items) <= limit {
+		return items, nil
+	}
+	items = items[:limit]
+	next := cursorOf(items[len(items)-1])
+	return items, &next
+}
+
+// countTotal runs a COUNT query when the caller asked for totals
+func countTotal(ctx context.Context, opts PageOptions, query string, args ...any) (*int64, error) {
+	if !opts.WithTotal {
+		return nil, nil
+	}
+
+	var total int64
+	if err := DB.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return &total, nil
+}
@@ -0,0 +1,113 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"main/internal/models"
+)
+
+// GetFeed returns the personalized home feed of a user: posts on the walls
+// of accepted friends, posts followed users wrote on their own walls and
+// posts in subscribed communities, newest first.
+// Posts come with the author, the community (for community posts), reaction
+// and comment counts. The feed has no total: opts.WithTotal is ignored.
+func GetFeed(
+	ctx context.Context,
+	userID int64,
+	opts PageOptions,
+) (posts []*models.Post, info PageInfo, err error) {
+	query := `
+		WITH friends AS (
+			SELECT friend_id AS id FROM friendships
+			WHERE user_id = $1 AND status = 'accepted'
+			UNION
+			SELECT user_id AS id FROM friendships
+			WHERE friend_id = $1 AND status = 'accepted'
+		), followed AS (
+			SELECT followee_id AS id FROM follows
+			WHERE follower_id = $1
+		), subs AS (
+			SELECT community_id FROM community_subscriptions
+			WHERE user_id = $1
+		)
+		SELECT ` + postColumns + `,
+			u.username, COALESCE(u.avatar_url, ''),
+			COALESCE(c.name, ''), COALESCE(c.is_private, FALSE)
+		FROM posts p
+		JOIN users u ON u.id = p.author_id
+		LEFT JOIN communities c ON c.id = p.community_id
+		WHERE (
+			(p.target_type = 'user' AND p.wall_user_id IN (SELECT id FROM friends))
+			OR (p.target_type = 'user' AND p.author_id = p.wall_user_id
+				AND p.wall_user_id IN (SELECT id FROM followed))
+			OR (p.target_type = 'community' AND p.community_id IN (SELECT community_id FROM subs))
+		)
+		AND ($2::timestamp IS NULL OR (p.created_at, p.id) < ($2::timestamp, $3::bigint))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $4
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	// Запрашиваем на один пост больше, чтобы понять, есть ли следующая страница
+	rows, err := DB.QueryContext(ctx, query, userID, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer rows.Close()
+
+	posts = make([]*models.Post, 0, opts.Limit)
+
+	for rows.Next() {
+		post := &models.Post{}
+		author := &models.User{}
+		community := &models.Community{}
+
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Text,
+			&post.PicURL,
+			&post.TargetType,
+			&post.WallUserID,
+			&post.CommunityID,
+			&post.AuthorID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&author.Username,
+			&author.AvatarURL,
+			&community.Name,
+			&community.IsPrivate,
+		)
+		if err != nil {
+			return nil, info, fmt.Errorf("failed to scan feed post: %w", err)
+		}
+
+		author.ID = post.AuthorID
+		post.Author = author
+
+		if post.TargetType == models.PostTargetCommunity {
+			community.ID = post.CommunityID
+			post.Community = community
+		}
+
+		posts = append(posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	posts, info.NextCursor = trimPage(posts, opts.Limit, postCursor)
+
+	if err := LoadPostStats(ctx, posts, userID); err != nil {
+		return nil, info, err
+	}
+
+	if err := LoadPostMedia(ctx, posts); err != nil {
+		return nil, info, err
+	}
+
+	return posts, info, nil
+}
@@ -0,0 +1,149 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrAlreadyFollowing = errors.New("already following this user")
+	ErrNotFollowing     = errors.New("not following this user")
+)
+
+// FollowUser - запись в списке подписчиков или подписок
+type FollowUser struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	AvatarURL  string    `json:"avatar_url"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// Follow makes followerID follow followeeID. Подписка не зависит от дружбы:
+// заявки в друзья и подписки хранятся отдельно. Returns ErrBlocked if either
+// user blocked the other.
+func Follow(ctx context.Context, followerID, followeeID int64) error {
+	result, err := DB.ExecContext(ctx, `
+		INSERT INTO follows (follower_id, followee_id)
+		SELECT $1, $2
+		WHERE NOT EXISTS (
+			SELECT 1 FROM friendships
+			WHERE status = $3
+			AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		)
+	`, followerID, followeeID, models.FriendshipBlocked)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return ErrAlreadyFollowing
+			case "23503":
+				return ErrUserNotFound
+			}
+		}
+		return fmt.Errorf("failed to follow user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrBlocked
+	}
+
+	return nil
+}
+
+// Unfollow removes the follow of followerID on followeeID
+func Unfollow(ctx context.Context, followerID, followeeID int64) error {
+	result, err := DB.ExecContext(ctx,
+		`DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2`,
+		followerID, followeeID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to unfollow user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFollowing
+	}
+
+	return nil
+}
+
+// GetFollowers lists the users following userID, most recent first
+func GetFollowers(ctx context.Context, userID int64, opts PageOptions) ([]FollowUser, PageInfo, error) {
+	return listFollows(ctx, userID, opts, "followee_id", "follower_id")
+}
+
+// GetFollowing lists the users userID follows, most recent first
+func GetFollowing(ctx context.Context, userID int64, opts PageOptions) ([]FollowUser, PageInfo, error) {
+	return listFollows(ctx, userID, opts, "follower_id", "followee_id")
+}
+
+// listFollows pages through follows where column by equals userID, returning
+// the users in column other. Курсор - (created_at, id пользователя из списка).
+func listFollows(
+	ctx context.Context,
+	userID int64,
+	opts PageOptions,
+	by, other string,
+) ([]FollowUser, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts,
+		`SELECT COUNT(*) FROM follows WHERE `+by+` = $1`,
+		userID,
+	)
+	if err != nil {
+		return nil, info, err
+	}
+
+	query := `
+		SELECT u.id, u.username, COALESCE(u.avatar_url, ''), f.created_at
+		FROM follows f
+		JOIN users u ON u.id = f.` + other + `
+		WHERE f.` + by + ` = $1
+		AND ($2::timestamp IS NULL OR (f.created_at, f.` + other + `) < ($2::timestamp, $3::bigint))
+		ORDER BY f.created_at DESC, f.` + other + ` DESC
+		LIMIT $4
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, userID, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch follows: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]FollowUser, 0, opts.Limit)
+	for rows.Next() {
+		var u FollowUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.AvatarURL, &u.FollowedAt); err != nil {
+			return nil, info, fmt.Errorf("failed to scan follow: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	users, info.NextCursor = trimPage(users, opts.Limit, func(u FollowUser) Cursor {
+		return Cursor{CreatedAt: u.FollowedAt, ID: u.ID}
+	})
+
+	return users, info, nil
+}
@@ -0,0 +1,98 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+// GetFriendAdjacency returns the accepted friends of every given user as
+// seen by viewerID, sorted by ID. Users without friends are absent from the
+// map. Друзья, у которых есть блокировка со зрителем в любую сторону,
+// пропускаются: через них нельзя ни пройти, ни увидеть их в сети.
+func GetFriendAdjacency(ctx context.Context, viewerID int64, userIDs []int64) (map[int64][]int64, error) {
+	adjacency := make(map[int64][]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return adjacency, nil
+	}
+
+	const query = `
+		SELECT a.user_id, a.friend_id
+		FROM (
+			SELECT user_id, friend_id FROM friendships
+			WHERE status = 'accepted' AND user_id = ANY($1)
+			UNION
+			SELECT friend_id, user_id FROM friendships
+			WHERE status = 'accepted' AND friend_id = ANY($1)
+		) a
+		WHERE NOT EXISTS (
+			SELECT 1 FROM friendships b
+			WHERE b.status = $3
+			AND ((b.user_id = $2 AND b.friend_id = a.friend_id)
+				OR (b.user_id = a.friend_id AND b.friend_id = $2))
+		)
+		ORDER BY 1, 2
+	`
+
+	rows, err := DB.QueryContext(ctx, query, pq.Array(userIDs), viewerID, models.FriendshipBlocked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friend adjacency: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, friendID int64
+		if err := rows.Scan(&userID, &friendID); err != nil {
+			return nil, fmt.Errorf("failed to scan friend adjacency: %w", err)
+		}
+		adjacency[userID] = append(adjacency[userID], friendID)
+	}
+
+	return adjacency, rows.Err()
+}
+
+// AreFriends reports whether the two users have an accepted friendship
+func AreFriends(ctx context.Context, userID1, userID2 int64) (bool, error) {
+	var friends bool
+	err := DB.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM friendships
+			WHERE status = $3
+			AND ((user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1))
+		)
+	`, userID1, userID2, models.FriendshipAccepted).Scan(&friends)
+	if err != nil {
+		return false, fmt.Errorf("failed to check friendship: %w", err)
+	}
+	return friends, nil
+}
+
+// GetFriendUsersByIDs loads id and username of the given users.
+// Несуществующие ID просто отсутствуют в результате
+func GetFriendUsersByIDs(ctx context.Context, userIDs []int64) (map[int64]FriendUser, error) {
+	users := make(map[int64]FriendUser, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	const query = `SELECT id, username FROM users WHERE id = ANY($1)`
+
+	rows, err := DB.QueryContext(ctx, query, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user FriendUser
+		if err := rows.Scan(&user.ID, &user.Username); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users[user.ID] = user
+	}
+
+	return users, rows.Err()
+}
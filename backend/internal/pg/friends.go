@@ -1,9 +1,44 @@
 package pg
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrAlreadyFriends        = errors.New("users are already friends")
+	ErrFriendRequestExists   = errors.New("a friend request between these users is already pending")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrFriendRequestCooldown = errors.New("friend request was rejected recently")
+	ErrSelfFriendRequest     = errors.New("user cannot send a friend request to themselves")
+
+	ErrInvalidFriendRequestStatus = errors.New("friend request status must be 'accepted' or 'rejected'")
 )
 
+// FriendRequestCooldown - через сколько после отказа можно отправить заявку снова
+var FriendRequestCooldown = 7 * 24 * time.Hour
+
+// FriendRequestCooldownError is returned by CreateFriendRequest while the
+// cooldown after a rejection is running
+type FriendRequestCooldownError struct {
+	Until time.Time
+}
+
+func (e *FriendRequestCooldownError) Error() string {
+	return fmt.Sprintf("%s, try again after %s", ErrFriendRequestCooldown, e.Until.Format(time.RFC3339))
+}
+
+func (e *FriendRequestCooldownError) Unwrap() error {
+	return ErrFriendRequestCooldown
+}
+
 // --- Structs ---
 
 // FriendUser represents a user's public profile, suitable for JSON responses.
@@ -18,35 +53,126 @@ type IncomingRequest struct {
 	Sender    FriendUser `json:"sender"`
 }
 
+// OutgoingRequest represents a pending friend request the user has sent.
+type OutgoingRequest struct {
+	RequestID int64      `json:"request_id"`
+	Receiver  FriendUser `json:"receiver"`
+	SentAt    time.Time  `json:"sent_at"`
+}
+
 // --- Database Functions ---
 
 // CreateFriendRequest creates a new pending friendship request.
+// Returns ErrSelfFriendRequest if senderID == receiverID,
+// ErrBlocked if either user has blocked the other, ErrAlreadyFriends or
+// ErrFriendRequestExists if the users are already connected, and a
+// *FriendRequestCooldownError if the receiver rejected the sender less than
+// FriendRequestCooldown ago. A rejected request is reopened once the cooldown
+// has passed; a request the sender rejected earlier does not count.
 func CreateFriendRequest(senderID, receiverID int64) error {
 	if senderID == receiverID {
-		return fmt.Errorf("user cannot send a friend request to themselves")
+		return ErrSelfFriendRequest
 	}
 
-	var exists bool
+	ctx := context.Background()
+
+	blocked, err := IsBlockedEither(ctx, senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		SELECT EXISTS(
-			SELECT 1 FROM friendships 
-			WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
-		)`
-	err := DB.QueryRow(query, senderID, receiverID).Scan(&exists)
+		SELECT id, user_id, status, responded_at
+		FROM friendships
+		WHERE (user_id = $1 AND friend_id = $2) OR (user_id = $2 AND friend_id = $1)
+		FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, senderID, receiverID)
 	if err != nil {
 		return fmt.Errorf("failed to check for existing friendship: %w", err)
 	}
-	if exists {
-		return fmt.Errorf("a friend request or friendship already exists between these users")
+
+	var reopenID int64
+	var staleIDs []int64
+	for rows.Next() {
+		var id, fromID int64
+		var status string
+		var respondedAt sql.NullTime
+		if err := rows.Scan(&id, &fromID, &status, &respondedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan friendship: %w", err)
+		}
+
+		switch status {
+		case models.FriendshipAccepted:
+			rows.Close()
+			return ErrAlreadyFriends
+		case models.FriendshipPending:
+			rows.Close()
+			return ErrFriendRequestExists
+		case models.FriendshipBlocked:
+			rows.Close()
+			return ErrBlocked
+		case models.FriendshipRejected:
+			if fromID != senderID {
+				// Отправитель сам когда-то отклонил заявку получателя - она больше не нужна
+				staleIDs = append(staleIDs, id)
+				continue
+			}
+			if respondedAt.Valid {
+				until := respondedAt.Time.Add(FriendRequestCooldown)
+				if time.Now().UTC().Before(until) {
+					rows.Close()
+					return &FriendRequestCooldownError{Until: until}
+				}
+			}
+			reopenID = id
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check for existing friendship: %w", err)
+	}
+
+	for _, id := range staleIDs {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM friendships WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("failed to remove rejected request: %w", err)
+		}
 	}
 
-	insertQuery := "INSERT INTO friendships (user_id, friend_id, status) VALUES ($1, $2, 'pending')"
-	_, err = DB.Exec(insertQuery, senderID, receiverID)
+	if reopenID != 0 {
+		reopenQuery := `
+			UPDATE friendships
+			SET status = 'pending', created_at = NOW(), responded_at = NULL
+			WHERE id = $1`
+		_, err = tx.ExecContext(ctx, reopenQuery, reopenID)
+	} else {
+		insertQuery := "INSERT INTO friendships (user_id, friend_id, status) VALUES ($1, $2, 'pending')"
+		_, err = tx.ExecContext(ctx, insertQuery, senderID, receiverID)
+	}
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23503":
+				return ErrUserNotFound
+			case "23505":
+				// Та же заявка успела вставиться параллельным запросом
+				return ErrFriendRequestExists
+			}
+		}
 		return fmt.Errorf("failed to create friend request: %w", err)
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // GetFriendsByUserID retrieves a list of accepted friends for a given user.
@@ -134,53 +260,72 @@ func GetIncomingFriendRequests(receiverID int64) ([]IncomingRequest, error) {
 
 // UpdateFriendRequestStatus updates the status of a request ('accepted' or 'rejected').
 // It ensures that only the intended recipient of the request can update it.
-func UpdateFriendRequestStatus(requestID, receiverID int64, newStatus string) error {
-	if newStatus != "accepted" && newStatus != "rejected" {
-		return fmt.Errorf("invalid status: %s", newStatus)
+// Returns the sender's ID so that both sides' caches can be refreshed,
+// ErrInvalidFriendRequestStatus for any other status and
+// ErrFriendRequestNotFound if the user has no such pending request.
+func UpdateFriendRequestStatus(requestID, receiverID int64, newStatus string) (int64, error) {
+	if newStatus != models.FriendshipAccepted && newStatus != models.FriendshipRejected {
+		return 0, ErrInvalidFriendRequestStatus
 	}
 
-	// If accepting, we create a two-way friendship by creating the inverse relationship
-	if newStatus == "accepted" {
-		tx, err := DB.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
+	// Отклонённая строка остаётся: responded_at запускает отсрочку повторной заявки
+	query := `
+		UPDATE friendships SET status = $3, responded_at = NOW()
+		WHERE id = $1 AND friend_id = $2 AND status = 'pending'
+		RETURNING user_id`
 
-		// Update the original request
-		updateQuery := `UPDATE friendships SET status = 'accepted' WHERE id = $1 AND friend_id = $2 AND status = 'pending'`
-		result, err := tx.Exec(updateQuery, requestID, receiverID)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to accept friend request: %w", err)
+	var senderID int64
+	err := DB.QueryRow(query, requestID, receiverID, newStatus).Scan(&senderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrFriendRequestNotFound
 		}
+		return 0, fmt.Errorf("failed to update friend request: %w", err)
+	}
 
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to get affected rows for update: %w", err)
-		}
-		if rowsAffected == 0 {
-			tx.Rollback()
-			return fmt.Errorf("no pending friend request found with the specified ID for this user")
-		}
+	return senderID, nil
+}
 
-		return tx.Commit()
+// GetOutgoingFriendRequests retrieves all pending friend requests sent by a user.
+func GetOutgoingFriendRequests(senderID int64) ([]OutgoingRequest, error) {
+	query := `
+		SELECT f.id, u.id, u.username, f.created_at
+		FROM friendships f
+		JOIN users u ON f.friend_id = u.id
+		WHERE f.user_id = $1 AND f.status = 'pending'
+		ORDER BY f.created_at DESC, f.id DESC;
+	`
+	rows, err := DB.Query(query, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outgoing friend requests: %w", err)
 	}
+	defer rows.Close()
 
-	// If rejecting, just delete the request
-	deleteQuery := `DELETE FROM friendships WHERE id = $1 AND friend_id = $2 AND status = 'pending'`
-	result, err := DB.Exec(deleteQuery, requestID, receiverID)
-	if err != nil {
-		return fmt.Errorf("failed to reject friend request: %w", err)
+	var requests []OutgoingRequest
+	for rows.Next() {
+		var req OutgoingRequest
+		if err := rows.Scan(&req.RequestID, &req.Receiver.ID, &req.Receiver.Username, &req.SentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outgoing friend request: %w", err)
+		}
+		requests = append(requests, req)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return requests, rows.Err()
+}
+
+// CancelFriendRequest withdraws a pending request. Only its sender can do it.
+// Returns the receiver's ID.
+func CancelFriendRequest(requestID, senderID int64) (int64, error) {
+	query := `DELETE FROM friendships WHERE id = $1 AND user_id = $2 AND status = 'pending' RETURNING friend_id`
+
+	var receiverID int64
+	err := DB.QueryRow(query, requestID, senderID).Scan(&receiverID)
 	if err != nil {
-		return fmt.Errorf("failed to get affected rows for delete: %w", err)
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("no pending friend request found with the specified ID for this user to reject")
+		if err == sql.ErrNoRows {
+			return 0, ErrFriendRequestNotFound
+		}
+		return 0, fmt.Errorf("failed to cancel friend request: %w", err)
 	}
 
-	return nil
-}
\ No newline at end of file
+	return receiverID, nil
+}
@@ -0,0 +1,289 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GraphFilter narrows the community overlap graph
+// Нулевые значения TopN и FocusID означают "без ограничения"
+type GraphFilter struct {
+	MinOverlap int64 // минимум общих подписчиков у ребра
+	TopN       int   // оставить не больше N самых сильных рёбер у каждой вершины
+	FocusID    int64 // только сообщество и его соседи
+}
+
+// GraphNode - вершина графа: сообщество с числом подписчиков
+type GraphNode struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Subscribers int64     `json:"subscribers"`
+	IsPrivate   bool      `json:"is_private"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GraphLink - ребро графа, Value - число общих подписчиков
+type GraphLink struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+	Value  int64 `json:"value"`
+}
+
+// graphNeighborhood selects the focus community and its neighbors
+// ($1 - min overlap, $2 - focus ID). Пусто, если фокус не задан.
+const graphNeighborhood = `
+	hood AS (
+		SELECT $2::bigint AS id WHERE $2::bigint <> 0
+		UNION
+		SELECT CASE WHEN o.community_a = $2 THEN o.community_b ELSE o.community_a END
+		FROM community_overlap o
+		WHERE $2::bigint <> 0 AND (o.community_a = $2 OR o.community_b = $2) AND o.common >= $1
+	)`
+
+// GetGraphNodes streams the communities of the overlap graph ordered by ID,
+// isolated ones included. With a focus only its neighborhood is returned.
+func GetGraphNodes(ctx context.Context, filter GraphFilter, fn func(GraphNode) error) error {
+	query := `
+		WITH ` + graphNeighborhood + `, sizes AS (
+			SELECT community_id, COUNT(*) AS size
+			FROM community_subscriptions
+			GROUP BY community_id
+		)
+		SELECT c.id, c.name, COALESCE(c.description, ''), COALESCE(c.is_private, FALSE),
+			COALESCE(s.size, 0), c.created_at
+		FROM communities c
+		LEFT JOIN sizes s ON s.community_id = c.id
+		WHERE $2::bigint = 0 OR c.id IN (SELECT id FROM hood)
+		ORDER BY c.id
+	`
+
+	rows, err := DB.QueryContext(ctx, query, filter.MinOverlap, filter.FocusID)
+	if err != nil {
+		return fmt.Errorf("failed to query graph nodes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var node GraphNode
+		err := rows.Scan(
+			&node.ID,
+			&node.Name,
+			&node.Description,
+			&node.IsPrivate,
+			&node.Subscribers,
+			&node.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan graph node: %w", err)
+		}
+		if err := fn(node); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// GetGraphLinks streams the edges of the overlap graph from the precomputed
+// community_overlap table. An edge survives TopN if it is among the N
+// strongest edges of at least one of its endpoints.
+func GetGraphLinks(ctx context.Context, filter GraphFilter, fn func(GraphLink) error) error {
+	query := `
+		WITH ` + graphNeighborhood + `, edges AS (
+			SELECT o.community_a, o.community_b, o.common
+			FROM community_overlap o
+			WHERE o.common >= $1
+			AND ($2::bigint = 0 OR (
+				o.community_a IN (SELECT id FROM hood) AND o.community_b IN (SELECT id FROM hood)
+			))
+		), ranked AS (
+			SELECT node, other,
+				ROW_NUMBER() OVER (PARTITION BY node ORDER BY common DESC, other) AS rn
+			FROM (
+				SELECT community_a AS node, community_b AS other, common FROM edges
+				UNION ALL
+				SELECT community_b, community_a, common FROM edges
+			) d
+		)
+		SELECT e.community_a, e.community_b, e.common
+		FROM edges e
+		WHERE $3::int = 0 OR EXISTS (
+			SELECT 1 FROM ranked r
+			WHERE r.rn <= $3
+			AND ((r.node = e.community_a AND r.other = e.community_b)
+				OR (r.node = e.community_b AND r.other = e.community_a))
+		)
+		ORDER BY e.community_a, e.community_b
+	`
+
+	rows, err := DB.QueryContext(ctx, query, filter.MinOverlap, filter.FocusID, filter.TopN)
+	if err != nil {
+		return fmt.Errorf("failed to query graph links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link GraphLink
+		if err := rows.Scan(&link.Source, &link.Target, &link.Value); err != nil {
+			return fmt.Errorf("failed to scan graph link: %w", err)
+		}
+		if err := fn(link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// DenormalizedLink is the response structure the frontend expects.
+type DenormalizedLink struct {
+	ID1               int64  `json:"id_1"`
+	ID2               int64  `json:"id_2"`
+	Subscribers1      int64  `json:"subscribers_1"`
+	Subscribers2      int64  `json:"subscribers_2"`
+	CommonSubscribers int64  `json:"common_subscribers"`
+	Name1             string `json:"name_1"`
+	Desc1             string `json:"desc_1"`
+	Name2             string `json:"name_2"`
+	Desc2             string `json:"desc_2"`
+}
+
+// parseGraphFilter reads min_overlap, top and focus from the query string
+// and responds 400 on bad values
+func parseGraphFilter(c *gin.Context) (GraphFilter, bool) {
+	filter := GraphFilter{MinOverlap: 1}
+
+	if s := c.Query("min_overlap"); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_overlap must be a positive integer"})
+			return filter, false
+		}
+		filter.MinOverlap = v
+	}
+
+	if s := c.Query("top"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "top must be a positive integer"})
+			return filter, false
+		}
+		filter.TopN = v
+	}
+
+	if s := c.Query("focus"); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid focus community ID"})
+			return filter, false
+		}
+
+		if _, err := GetCommunity(c.Request.Context(), v); err != nil {
+			if errors.Is(err, ErrCommunityNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "community not found"})
+				return filter, false
+			}
+			log.Printf("Error getting focus community: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch community data"})
+			return filter, false
+		}
+		filter.FocusID = v
+	}
+
+	return filter, true
+}
+
+// GetGraphData returns the community overlap graph
+// GET /graph-data?min_overlap=2&top=5&focus=42&format=node-link
+// По умолчанию - плоский список рёбер (DenormalizedLink) для фронтенда,
+// format=node-link - {"nodes": [...], "links": [...]} вместе с изолированными сообществами,
+// format=graphml|gexf|dot|csv - выгрузка файлом для Gephi и Graphviz (см. exportGraph)
+func GetGraphData(c *gin.Context) {
+	filter, ok := parseGraphFilter(c)
+	if !ok {
+		return
+	}
+
+	format := c.DefaultQuery("format", "links")
+	if export, ok := graphExportFormats[format]; ok {
+		exportGraph(c, export, format, filter)
+		return
+	}
+	if format != "links" && format != "node-link" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported format",
+			"allowed": []string{"links", "node-link", "graphml", "gexf", "dot", "csv"},
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	nodes := make([]GraphNode, 0)
+	nodeIndex := make(map[int64]int)
+	err := GetGraphNodes(ctx, filter, func(node GraphNode) error {
+		nodeIndex[node.ID] = len(nodes)
+		nodes = append(nodes, node)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying graph nodes: %v", err)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch community data"},
+		)
+		return
+	}
+
+	links := make([]GraphLink, 0)
+	err = GetGraphLinks(ctx, filter, func(link GraphLink) error {
+		links = append(links, link)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error querying graph links: %v", err)
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "Failed to fetch community links"},
+		)
+		return
+	}
+
+	if format == "node-link" {
+		c.JSON(http.StatusOK, gin.H{"nodes": nodes, "links": links})
+		return
+	}
+
+	response := make([]DenormalizedLink, 0, len(links))
+	for _, link := range links {
+		si, okSource := nodeIndex[link.Source]
+		ti, okTarget := nodeIndex[link.Target]
+		if !okSource || !okTarget {
+			// сообщество появилось между двумя запросами
+			continue
+		}
+		source, target := nodes[si], nodes[ti]
+
+		response = append(response, DenormalizedLink{
+			ID1:               source.ID,
+			ID2:               target.ID,
+			Subscribers1:      source.Subscribers,
+			Subscribers2:      target.Subscribers,
+			CommonSubscribers: link.Value,
+			Name1:             source.Name,
+			Desc1:             source.Description,
+			Name2:             target.Name,
+			Desc2:             target.Description,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
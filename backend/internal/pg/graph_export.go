@@ -0,0 +1,318 @@
+package pg
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// graphEncoder writes the overlap graph in one export format.
+// Методы вызываются по порядку: begin, node..., edges, edge..., end
+type graphEncoder interface {
+	begin() error
+	node(GraphNode) error
+	edges() error
+	edge(GraphLink) error
+	end() error
+}
+
+type graphExportFormat struct {
+	contentType string
+	extension   string
+	encoder     func(w *bufio.Writer) graphEncoder
+}
+
+var graphExportFormats = map[string]graphExportFormat{
+	"graphml": {
+		contentType: "application/graphml+xml; charset=utf-8",
+		extension:   "graphml",
+		encoder:     func(w *bufio.Writer) graphEncoder { return &graphMLEncoder{w: w} },
+	},
+	"gexf": {
+		contentType: "application/gexf+xml; charset=utf-8",
+		extension:   "gexf",
+		encoder:     func(w *bufio.Writer) graphEncoder { return &gexfEncoder{w: w} },
+	},
+	"dot": {
+		contentType: "text/vnd.graphviz; charset=utf-8",
+		extension:   "dot",
+		encoder:     func(w *bufio.Writer) graphEncoder { return &dotEncoder{w: w} },
+	},
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		encoder:     func(w *bufio.Writer) graphEncoder { return &csvEncoder{w: csv.NewWriter(w)} },
+	},
+}
+
+// graphExportBuffer - сколько байт копим перед отправкой клиенту.
+// Пока буфер не сброшен, ошибку ещё можно вернуть обычным JSON.
+const graphExportBuffer = 32 << 10
+
+// exportGraph streams the graph to the client row by row, without holding
+// it in memory. A CSV export carries one table per request:
+// ?table=edges (default) or ?table=nodes.
+func exportGraph(c *gin.Context, format graphExportFormat, name string, filter GraphFilter) {
+	w := bufio.NewWriterSize(c.Writer, graphExportBuffer)
+	enc := format.encoder(w)
+
+	withNodes, withLinks := true, true
+	if name == "csv" {
+		switch c.DefaultQuery("table", "edges") {
+		case "edges":
+			withNodes = false
+		case "nodes":
+			withLinks = false
+			enc = &csvEncoder{w: csv.NewWriter(w), nodes: true}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "table must be nodes or edges"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", format.contentType)
+	c.Header("Content-Disposition", `attachment; filename="community-graph.`+format.extension+`"`)
+	c.Status(http.StatusOK)
+
+	err := writeGraph(c.Request.Context(), filter, enc, withNodes, withLinks)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		log.Printf("Error exporting graph as %s: %v", name, err)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(
+				http.StatusInternalServerError,
+				gin.H{"error": "Failed to export community graph"},
+			)
+			return
+		}
+		// Заголовки уже ушли, остаётся оборвать документ
+		c.Abort()
+	}
+}
+
+func writeGraph(ctx context.Context, filter GraphFilter, enc graphEncoder, withNodes, withLinks bool) error {
+	if err := enc.begin(); err != nil {
+		return err
+	}
+	if withNodes {
+		if err := GetGraphNodes(ctx, filter, enc.node); err != nil {
+			return err
+		}
+	}
+	if err := enc.edges(); err != nil {
+		return err
+	}
+	if withLinks {
+		if err := GetGraphLinks(ctx, filter, enc.edge); err != nil {
+			return err
+		}
+	}
+	return enc.end()
+}
+
+func formatGraphTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// xmlEscape escapes text for both element content and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// graphMLEncoder writes GraphML 1.0
+// http://graphml.graphdrawing.org/specification.html
+type graphMLEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *graphMLEncoder) begin() error {
+	_, err := io.WriteString(e.w, xml.Header+
+		`<graphml xmlns="http://graphml.graphdrawing.org/xmlns"`+
+		` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`+
+		` xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">
+  <key id="name" for="node" attr.name="name" attr.type="string"/>
+  <key id="description" for="node" attr.name="description" attr.type="string"/>
+  <key id="subscribers" for="node" attr.name="subscribers" attr.type="long"/>
+  <key id="is_private" for="node" attr.name="is_private" attr.type="boolean"/>
+  <key id="created_at" for="node" attr.name="created_at" attr.type="string"/>
+  <key id="weight" for="edge" attr.name="weight" attr.type="long"/>
+  <graph id="communities" edgedefault="undirected">
+`)
+	return err
+}
+
+func (e *graphMLEncoder) node(n GraphNode) error {
+	_, err := fmt.Fprintf(e.w, `    <node id="n%d">
+      <data key="name">%s</data>
+      <data key="description">%s</data>
+      <data key="subscribers">%d</data>
+      <data key="is_private">%t</data>
+      <data key="created_at">%s</data>
+    </node>
+`, n.ID, xmlEscape(n.Name), xmlEscape(n.Description), n.Subscribers, n.IsPrivate, formatGraphTime(n.CreatedAt))
+	return err
+}
+
+func (e *graphMLEncoder) edges() error { return nil }
+
+func (e *graphMLEncoder) edge(l GraphLink) error {
+	_, err := fmt.Fprintf(e.w, `    <edge id="e%d_%d" source="n%d" target="n%d">
+      <data key="weight">%d</data>
+    </edge>
+`, l.Source, l.Target, l.Source, l.Target, l.Value)
+	return err
+}
+
+func (e *graphMLEncoder) end() error {
+	_, err := io.WriteString(e.w, "  </graph>\n</graphml>\n")
+	return err
+}
+
+// gexfEncoder writes GEXF 1.3 (формат Gephi)
+// https://gexf.net/schema.html
+type gexfEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *gexfEncoder) begin() error {
+	_, err := fmt.Fprintf(e.w, xml.Header+
+		`<gexf xmlns="http://gexf.net/1.3"`+
+		` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`+
+		` xsi:schemaLocation="http://gexf.net/1.3 http://gexf.net/1.3/gexf.xsd" version="1.3">
+  <meta lastmodifieddate="%s">
+    <description>Community subscriber overlap</description>
+  </meta>
+  <graph mode="static" defaultedgetype="undirected">
+    <attributes class="node">
+      <attribute id="description" title="description" type="string"/>
+      <attribute id="subscribers" title="subscribers" type="long"/>
+      <attribute id="is_private" title="is_private" type="boolean"/>
+      <attribute id="created_at" title="created_at" type="string"/>
+    </attributes>
+    <nodes>
+`, time.Now().UTC().Format("2006-01-02"))
+	return err
+}
+
+func (e *gexfEncoder) node(n GraphNode) error {
+	_, err := fmt.Fprintf(e.w, `      <node id="%d" label="%s">
+        <attvalues>
+          <attvalue for="description" value="%s"/>
+          <attvalue for="subscribers" value="%d"/>
+          <attvalue for="is_private" value="%t"/>
+          <attvalue for="created_at" value="%s"/>
+        </attvalues>
+      </node>
+`, n.ID, xmlEscape(n.Name), xmlEscape(n.Description), n.Subscribers, n.IsPrivate, formatGraphTime(n.CreatedAt))
+	return err
+}
+
+func (e *gexfEncoder) edges() error {
+	_, err := io.WriteString(e.w, "    </nodes>\n    <edges>\n")
+	return err
+}
+
+func (e *gexfEncoder) edge(l GraphLink) error {
+	_, err := fmt.Fprintf(e.w, "      <edge id=\"%d_%d\" source=\"%d\" target=\"%d\" weight=\"%d\"/>\n",
+		l.Source, l.Target, l.Source, l.Target, l.Value)
+	return err
+}
+
+func (e *gexfEncoder) end() error {
+	_, err := io.WriteString(e.w, "    </edges>\n  </graph>\n</gexf>\n")
+	return err
+}
+
+// dotEncoder writes a Graphviz DOT undirected graph
+type dotEncoder struct {
+	w *bufio.Writer
+}
+
+// dotQuote returns s as a DOT double-quoted string
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\r", "")
+	return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+}
+
+func (e *dotEncoder) begin() error {
+	_, err := io.WriteString(e.w, "graph communities {\n")
+	return err
+}
+
+func (e *dotEncoder) node(n GraphNode) error {
+	_, err := fmt.Fprintf(e.w, "  %d [label=%s, description=%s, subscribers=%d, is_private=%t, created_at=%s];\n",
+		n.ID, dotQuote(n.Name), dotQuote(n.Description), n.Subscribers, n.IsPrivate,
+		dotQuote(formatGraphTime(n.CreatedAt)))
+	return err
+}
+
+func (e *dotEncoder) edges() error { return nil }
+
+func (e *dotEncoder) edge(l GraphLink) error {
+	_, err := fmt.Fprintf(e.w, "  %d -- %d [weight=%d];\n", l.Source, l.Target, l.Value)
+	return err
+}
+
+func (e *dotEncoder) end() error {
+	_, err := io.WriteString(e.w, "}\n")
+	return err
+}
+
+// csvEncoder writes one RFC 4180 table, edges or nodes, with the column
+// names Gephi's spreadsheet import recognizes
+type csvEncoder struct {
+	w     *csv.Writer
+	nodes bool
+}
+
+func (e *csvEncoder) begin() error {
+	if e.nodes {
+		return e.w.Write([]string{"Id", "Label", "Description", "Subscribers", "IsPrivate", "CreatedAt"})
+	}
+	return e.w.Write([]string{"Source", "Target", "Type", "Weight"})
+}
+
+func (e *csvEncoder) node(n GraphNode) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(n.ID, 10),
+		n.Name,
+		n.Description,
+		strconv.FormatInt(n.Subscribers, 10),
+		strconv.FormatBool(n.IsPrivate),
+		formatGraphTime(n.CreatedAt),
+	})
+}
+
+func (e *csvEncoder) edges() error { return nil }
+
+func (e *csvEncoder) edge(l GraphLink) error {
+	return e.w.Write([]string{
+		strconv.FormatInt(l.Source, 10),
+		strconv.FormatInt(l.Target, 10),
+		"Undirected",
+		strconv.FormatInt(l.Value, 10),
+	})
+}
+
+func (e *csvEncoder) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,320 @@
+package pg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	msk = time.FixedZone("MSK", 3*60*60)
+
+	// Имена со спецсимволами каждого формата
+	testGraphNodes = []GraphNode{
+		{ID: 1, Name: "Cats & Dogs", Description: "a < b > c", Subscribers: 10, CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, msk)},
+		{ID: 2, Name: `The "Quoted" one`, Description: `back\slash`, Subscribers: 0, IsPrivate: true, CreatedAt: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{ID: 3, Name: "line one\nline two", Description: "", Subscribers: 7, CreatedAt: time.Date(2022, 12, 31, 23, 59, 59, 0, time.UTC)},
+		{ID: 4, Name: "Фанаты фантастики", Description: "'single' & \"double\"", Subscribers: 3, CreatedAt: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	testGraphLinks = []GraphLink{
+		{Source: 1, Target: 2, Value: 5},
+		{Source: 1, Target: 3, Value: 1},
+		{Source: 3, Target: 4, Value: 12},
+	}
+)
+
+// encodeGraph runs enc over nodes and links the way writeGraph does
+func encodeGraph(t *testing.T, newEncoder func(*bufio.Writer) graphEncoder, nodes []GraphNode, links []GraphLink) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	enc := newEncoder(w)
+
+	if err := enc.begin(); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	for _, n := range nodes {
+		if err := enc.node(n); err != nil {
+			t.Fatalf("node %d: %v", n.ID, err)
+		}
+	}
+	if err := enc.edges(); err != nil {
+		t.Fatalf("edges: %v", err)
+	}
+	for _, l := range links {
+		if err := enc.edge(l); err != nil {
+			t.Fatalf("edge %d-%d: %v", l.Source, l.Target, err)
+		}
+	}
+	if err := enc.end(); err != nil {
+		t.Fatalf("end: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGraphMLEncoder(t *testing.T) {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		XMLName xml.Name `xml:"graphml"`
+		Keys    []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			Nodes []struct {
+				ID   string `xml:"id,attr"`
+				Data []data `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Data   []data `xml:"data"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+
+	out := encodeGraph(t, graphExportFormats["graphml"].encoder, testGraphNodes, testGraphLinks)
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid GraphML: %v\n%s", err, out)
+	}
+
+	if len(doc.Keys) != 6 {
+		t.Errorf("%d keys, want 6", len(doc.Keys))
+	}
+	if len(doc.Graph.Nodes) != len(testGraphNodes) {
+		t.Fatalf("%d nodes, want %d", len(doc.Graph.Nodes), len(testGraphNodes))
+	}
+
+	for i, want := range testGraphNodes {
+		t.Run(want.Name, func(t *testing.T) {
+			node := doc.Graph.Nodes[i]
+			if node.ID != "n"+strconv.FormatInt(want.ID, 10) {
+				t.Errorf("id = %q", node.ID)
+			}
+
+			got := make(map[string]string)
+			for _, d := range node.Data {
+				got[d.Key] = d.Value
+			}
+			wantData := map[string]string{
+				"name":        want.Name,
+				"description": want.Description,
+				"subscribers": strconv.FormatInt(want.Subscribers, 10),
+				"is_private":  strconv.FormatBool(want.IsPrivate),
+				"created_at":  want.CreatedAt.UTC().Format(time.RFC3339),
+			}
+			for key, value := range wantData {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+
+	if len(doc.Graph.Edges) != len(testGraphLinks) {
+		t.Fatalf("%d edges, want %d", len(doc.Graph.Edges), len(testGraphLinks))
+	}
+	for i, want := range testGraphLinks {
+		e := doc.Graph.Edges[i]
+		if e.Source != "n"+strconv.FormatInt(want.Source, 10) || e.Target != "n"+strconv.FormatInt(want.Target, 10) {
+			t.Errorf("edge %d: %s -> %s", i, e.Source, e.Target)
+		}
+		if len(e.Data) != 1 || e.Data[0].Key != "weight" || e.Data[0].Value != strconv.FormatInt(want.Value, 10) {
+			t.Errorf("edge %d: data = %+v", i, e.Data)
+		}
+	}
+}
+
+func TestGEXFEncoder(t *testing.T) {
+	var doc struct {
+		XMLName xml.Name `xml:"gexf"`
+		Version string   `xml:"version,attr"`
+		Graph   struct {
+			Nodes []struct {
+				ID        string `xml:"id,attr"`
+				Label     string `xml:"label,attr"`
+				AttValues []struct {
+					For   string `xml:"for,attr"`
+					Value string `xml:"value,attr"`
+				} `xml:"attvalues>attvalue"`
+			} `xml:"nodes>node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+				Weight string `xml:"weight,attr"`
+			} `xml:"edges>edge"`
+		} `xml:"graph"`
+	}
+
+	out := encodeGraph(t, graphExportFormats["gexf"].encoder, testGraphNodes, testGraphLinks)
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("invalid GEXF: %v\n%s", err, out)
+	}
+
+	if doc.Version != "1.3" {
+		t.Errorf("version = %q", doc.Version)
+	}
+	if len(doc.Graph.Nodes) != len(testGraphNodes) {
+		t.Fatalf("%d nodes, want %d", len(doc.Graph.Nodes), len(testGraphNodes))
+	}
+
+	for i, want := range testGraphNodes {
+		t.Run(want.Name, func(t *testing.T) {
+			node := doc.Graph.Nodes[i]
+			if node.ID != strconv.FormatInt(want.ID, 10) {
+				t.Errorf("id = %q", node.ID)
+			}
+			// Перевод строки и кавычки в атрибуте должны пережить разбор
+			if node.Label != want.Name {
+				t.Errorf("label = %q, want %q", node.Label, want.Name)
+			}
+
+			got := make(map[string]string)
+			for _, v := range node.AttValues {
+				got[v.For] = v.Value
+			}
+			if got["description"] != want.Description {
+				t.Errorf("description = %q, want %q", got["description"], want.Description)
+			}
+			if got["created_at"] != want.CreatedAt.UTC().Format(time.RFC3339) {
+				t.Errorf("created_at = %q", got["created_at"])
+			}
+		})
+	}
+
+	if len(doc.Graph.Edges) != len(testGraphLinks) {
+		t.Fatalf("%d edges, want %d", len(doc.Graph.Edges), len(testGraphLinks))
+	}
+	for i, want := range testGraphLinks {
+		e := doc.Graph.Edges[i]
+		if e.Source != strconv.FormatInt(want.Source, 10) ||
+			e.Target != strconv.FormatInt(want.Target, 10) ||
+			e.Weight != strconv.FormatInt(want.Value, 10) {
+			t.Errorf("edge %d = %+v, want %+v", i, e, want)
+		}
+	}
+}
+
+func TestDotQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "plain", want: `"plain"`},
+		{in: "Cats & Dogs", want: `"Cats & Dogs"`},
+		{in: `The "Quoted" one`, want: `"The \"Quoted\" one"`},
+		{in: `back\slash`, want: `"back\\slash"`},
+		{in: "line one\nline two", want: `"line one\nline two"`},
+		{in: "crlf\r\nline", want: `"crlf\nline"`},
+		{in: `\"`, want: `"\\\""`},
+		{in: "", want: `""`},
+	}
+
+	for _, tt := range tests {
+		if got := dotQuote(tt.in); got != tt.want {
+			t.Errorf("dotQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDOTEncoder(t *testing.T) {
+	out := string(encodeGraph(t, graphExportFormats["dot"].encoder, testGraphNodes, testGraphLinks))
+
+	if !strings.HasPrefix(out, "graph communities {\n") || !strings.HasSuffix(out, "}\n") {
+		t.Fatalf("not a DOT graph:\n%s", out)
+	}
+
+	wantLines := []string{
+		`  1 [label="Cats & Dogs", description="a < b > c", subscribers=10, is_private=false, created_at="2024-03-01T09:00:00Z"];`,
+		`  2 [label="The \"Quoted\" one", description="back\\slash", subscribers=0, is_private=true, created_at="2023-01-02T03:04:05Z"];`,
+		`  3 [label="line one\nline two", description="", subscribers=7, is_private=false, created_at="2022-12-31T23:59:59Z"];`,
+		`  1 -- 2 [weight=5];`,
+		`  3 -- 4 [weight=12];`,
+	}
+	lines := strings.Split(out, "\n")
+	for _, want := range wantLines {
+		found := false
+		for _, line := range lines {
+			if line == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing line %s\nin:\n%s", want, out)
+		}
+	}
+
+	// Каждый узел и ребро - одна строка: переводы строк в именах экранированы
+	if got, want := len(lines), 1+len(testGraphNodes)+len(testGraphLinks)+2; got != want {
+		t.Errorf("%d lines, want %d", got, want)
+	}
+}
+
+func TestCSVEncoder(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes bool
+		want  [][]string
+	}{
+		{
+			name:  "nodes",
+			nodes: true,
+			want: [][]string{
+				{"Id", "Label", "Description", "Subscribers", "IsPrivate", "CreatedAt"},
+				{"1", "Cats & Dogs", "a < b > c", "10", "false", "2024-03-01T09:00:00Z"},
+				{"2", `The "Quoted" one`, `back\slash`, "0", "true", "2023-01-02T03:04:05Z"},
+				{"3", "line one\nline two", "", "7", "false", "2022-12-31T23:59:59Z"},
+				{"4", "Фанаты фантастики", "'single' & \"double\"", "3", "false", "2021-06-01T00:00:00Z"},
+			},
+		},
+		{
+			name: "edges",
+			want: [][]string{
+				{"Source", "Target", "Type", "Weight"},
+				{"1", "2", "Undirected", "5"},
+				{"1", "3", "Undirected", "1"},
+				{"3", "4", "Undirected", "12"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Как в exportGraph: одна таблица на запрос
+			nodes, links := testGraphNodes, []GraphLink(nil)
+			if !tt.nodes {
+				nodes, links = nil, testGraphLinks
+			}
+			newEncoder := func(w *bufio.Writer) graphEncoder {
+				return &csvEncoder{w: csv.NewWriter(w), nodes: tt.nodes}
+			}
+
+			out := encodeGraph(t, newEncoder, nodes, links)
+			records, err := csv.NewReader(bytes.NewReader(out)).ReadAll()
+			if err != nil {
+				t.Fatalf("invalid CSV: %v\n%s", err, out)
+			}
+
+			if len(records) != len(tt.want) {
+				t.Fatalf("%d records, want %d", len(records), len(tt.want))
+			}
+			for i := range tt.want {
+				if strings.Join(records[i], "|") != strings.Join(tt.want[i], "|") {
+					t.Errorf("record %d = %q, want %q", i, records[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
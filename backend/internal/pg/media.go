@@ -0,0 +1,223 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrMediaNotFound = errors.New("media not found")
+	ErrMediaAttached = errors.New("media is already attached to a post")
+)
+
+const mediaColumns = `
+	m.id, m.owner_id, m.storage_key, m.content_type, m.width, m.height,
+	m.size_bytes, m.created_at`
+
+func scanMedia(row rowScanner, m *models.Media, extra ...any) error {
+	dest := []any{
+		&m.ID,
+		&m.OwnerID,
+		&m.StorageKey,
+		&m.ContentType,
+		&m.Width,
+		&m.Height,
+		&m.SizeBytes,
+		&m.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	m.SetVariantURLs()
+	return nil
+}
+
+// CreateMedia records an uploaded file whose variants are already stored.
+// Заполняет ID, CreatedAt и Variants
+func CreateMedia(ctx context.Context, m *models.Media) error {
+	const query = `
+		INSERT INTO media (owner_id, storage_key, content_type, width, height, size_bytes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+
+	err := DB.QueryRowContext(ctx, query,
+		m.OwnerID,
+		m.StorageKey,
+		m.ContentType,
+		m.Width,
+		m.Height,
+		m.SizeBytes,
+	).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create media: %w", err)
+	}
+
+	m.SetVariantURLs()
+	return nil
+}
+
+// GetMedia returns a media file with the post it is attached to (PostID 0
+// for an unattached upload)
+func GetMedia(ctx context.Context, mediaID int64) (*models.Media, error) {
+	query := `SELECT ` + mediaColumns + `, COALESCE(pm.post_id, 0)
+		FROM media m
+		LEFT JOIN post_media pm ON pm.media_id = m.id
+		WHERE m.id = $1
+	`
+
+	m := &models.Media{}
+	err := scanMedia(DB.QueryRowContext(ctx, query, mediaID), m, &m.PostID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMediaNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch media: %w", err)
+	}
+
+	return m, nil
+}
+
+// attachPostMedia attaches post.MediaIDs to a freshly created post in the
+// given order and fills post.Media. Every file must belong to the post
+// author and must not be attached anywhere yet.
+func attachPostMedia(ctx context.Context, tx *sql.Tx, post *models.Post) error {
+	post.Media = make([]*models.Media, 0, len(post.MediaIDs))
+	if len(post.MediaIDs) == 0 {
+		return nil
+	}
+
+	// Блокируем строки, чтобы очистка сирот не удалила их до вставки
+	var found int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM media WHERE id = ANY($1) AND owner_id = $2 FOR UPDATE
+		) locked
+	`, pq.Array(post.MediaIDs), post.AuthorID).Scan(&found)
+	if err != nil {
+		return fmt.Errorf("failed to lock media: %w", err)
+	}
+	if found != len(post.MediaIDs) {
+		return ErrMediaNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_media (post_id, media_id, position)
+		SELECT $1, t.id, t.ord - 1
+		FROM unnest($2::bigint[]) WITH ORDINALITY AS t(id, ord)
+	`, post.ID, pq.Array(post.MediaIDs))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrMediaAttached
+		}
+		return fmt.Errorf("failed to attach media: %w", err)
+	}
+
+	query := `SELECT ` + mediaColumns + `
+		FROM post_media pm
+		JOIN media m ON m.id = pm.media_id
+		WHERE pm.post_id = $1
+		ORDER BY pm.position
+	`
+
+	rows, err := tx.QueryContext(ctx, query, post.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch post media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &models.Media{PostID: post.ID}
+		if err := scanMedia(rows, m); err != nil {
+			return fmt.Errorf("failed to scan post media: %w", err)
+		}
+		post.Media = append(post.Media, m)
+	}
+
+	return rows.Err()
+}
+
+// LoadPostMedia fills Media of a page of posts in one query, in attachment
+// order
+func LoadPostMedia(ctx context.Context, posts []*models.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Post, len(posts))
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		post.Media = make([]*models.Media, 0)
+		byID[post.ID] = post
+		ids = append(ids, post.ID)
+	}
+
+	query := `SELECT ` + mediaColumns + `, pm.post_id
+		FROM post_media pm
+		JOIN media m ON m.id = pm.media_id
+		WHERE pm.post_id = ANY($1)
+		ORDER BY pm.post_id, pm.position
+	`
+
+	rows, err := DB.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to fetch post media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &models.Media{}
+		if err := scanMedia(rows, m, &m.PostID); err != nil {
+			return fmt.Errorf("failed to scan post media: %w", err)
+		}
+		post := byID[m.PostID]
+		post.Media = append(post.Media, m)
+	}
+
+	return rows.Err()
+}
+
+// DeleteOrphanMedia deletes up to limit uploads created before olderThan that
+// were never attached to a post (or whose post was deleted) and returns them
+// so the caller can remove their files. Строки, заблокированные
+// прикреплением к посту прямо сейчас, пропускаются.
+func DeleteOrphanMedia(ctx context.Context, olderThan time.Time, limit int) ([]models.Media, error) {
+	const query = `
+		DELETE FROM media m
+		WHERE m.id IN (
+			SELECT o.id FROM media o
+			WHERE o.created_at < $1
+			AND NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = o.id)
+			ORDER BY o.created_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		AND NOT EXISTS (SELECT 1 FROM post_media pm WHERE pm.media_id = m.id)
+		RETURNING m.id, m.storage_key, m.content_type
+	`
+
+	rows, err := DB.QueryContext(ctx, query, olderThan, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete orphan media: %w", err)
+	}
+	defer rows.Close()
+
+	var deleted []models.Media
+	for rows.Next() {
+		var m models.Media
+		if err := rows.Scan(&m.ID, &m.StorageKey, &m.ContentType); err != nil {
+			return nil, fmt.Errorf("failed to scan orphan media: %w", err)
+		}
+		deleted = append(deleted, m)
+	}
+
+	return deleted, rows.Err()
+}
@@ -0,0 +1,124 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var ErrNoReaction = errors.New("no reaction")
+
+// SetPostReaction sets the user's reaction on a post, replacing the previous
+// one if any. Returns ErrPostNotFound if the post does not exist.
+func SetPostReaction(ctx context.Context, postID, userID int64, reaction string) error {
+	const query = `
+		INSERT INTO post_reactions (post_id, user_id, reaction, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (post_id, user_id)
+		DO UPDATE SET reaction = EXCLUDED.reaction, created_at = EXCLUDED.created_at
+		WHERE post_reactions.reaction <> EXCLUDED.reaction
+	`
+
+	_, err := DB.ExecContext(ctx, query, postID, userID, reaction)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrPostNotFound
+		}
+		return fmt.Errorf("failed to set post reaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemovePostReaction removes the user's reaction from a post
+// Возвращает ErrNoReaction, если реакции не было
+func RemovePostReaction(ctx context.Context, postID, userID int64) error {
+	const query = `
+		DELETE FROM post_reactions
+		WHERE post_id = $1 AND user_id = $2
+	`
+
+	result, err := DB.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove post reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrNoReaction
+	}
+
+	return nil
+}
+
+// GetPostReactions lists who reacted to a post, newest first, one keyset
+// page at a time. kind filters by reaction, "" lists all of them.
+func GetPostReactions(
+	ctx context.Context,
+	postID int64,
+	kind string,
+	opts PageOptions,
+) ([]models.PostReaction, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	var kindArg sql.NullString
+	if kind != "" {
+		kindArg = sql.NullString{String: kind, Valid: true}
+	}
+
+	info.Total, err = countTotal(ctx, opts, `
+		SELECT COUNT(*) FROM post_reactions
+		WHERE post_id = $1 AND ($2::reaction_kind IS NULL OR reaction = $2::reaction_kind)
+	`, postID, kindArg)
+	if err != nil {
+		return nil, info, err
+	}
+
+	const query = `
+		SELECT u.id, u.username, COALESCE(u.avatar_url, ''), pr.reaction, pr.created_at
+		FROM post_reactions pr
+		JOIN users u ON u.id = pr.user_id
+		WHERE pr.post_id = $1
+		AND ($2::reaction_kind IS NULL OR pr.reaction = $2::reaction_kind)
+		AND ($3::timestamp IS NULL OR (pr.created_at, pr.user_id) < ($3::timestamp, $4::bigint))
+		ORDER BY pr.created_at DESC, pr.user_id DESC
+		LIMIT $5
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, postID, kindArg, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch post reactions: %w", err)
+	}
+	defer rows.Close()
+
+	reactions := make([]models.PostReaction, 0, opts.Limit)
+	for rows.Next() {
+		var r models.PostReaction
+		if err := rows.Scan(&r.UserID, &r.Username, &r.AvatarURL, &r.Reaction, &r.CreatedAt); err != nil {
+			return nil, info, fmt.Errorf("failed to scan post reaction: %w", err)
+		}
+		reactions = append(reactions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	reactions, info.NextCursor = trimPage(reactions, opts.Limit, func(r models.PostReaction) Cursor {
+		return Cursor{CreatedAt: r.CreatedAt, ID: r.UserID}
+	})
+
+	return reactions, info, nil
+}
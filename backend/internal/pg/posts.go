@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -11,33 +12,99 @@ import (
 	"main/internal/models"
 )
 
-var (
-	ErrPostNotFound = errors.New("post not found")
-	ErrAlreadyLiked = errors.New("already liked")
-	ErrNotLiked     = errors.New("not liked")
-)
+var ErrPostNotFound = errors.New("post not found")
+
+var ErrInvalidPostTarget = errors.New("invalid post target")
+
+// postColumns - общий список колонок для выборок постов (см. scanPost)
+const postColumns = `
+	p.id, p.title, p.text, COALESCE(p.pic_url, ''), p.target_type,
+	COALESCE(p.wall_user_id, 0), COALESCE(p.community_id, 0),
+	p.author_id, p.created_at, p.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPost(row rowScanner, post *models.Post) error {
+	return row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Text,
+		&post.PicURL,
+		&post.TargetType,
+		&post.WallUserID,
+		&post.CommunityID,
+		&post.AuthorID,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	)
+}
+
+// targetColumn returns the posts column that holds the target ID
+func targetColumn(target models.PostTarget) (string, error) {
+	switch target.Type {
+	case models.PostTargetUser:
+		return "wall_user_id", nil
+	case models.PostTargetCommunity:
+		return "community_id", nil
+	default:
+		return "", ErrInvalidPostTarget
+	}
+}
 
 // CreatePost creates a new post in the database
+// Куда публикуется пост, задаётся через post.TargetType и WallUserID/CommunityID
+// Файлы из post.MediaIDs прикрепляются в той же транзакции
+// (ErrMediaNotFound, ErrMediaAttached)
 // Возвращает созданный пост с заполненным ID и временем создания
 func CreatePost(ctx context.Context, post *models.Post) error {
 	const query = `
-		INSERT INTO posts (title, text, pic_url, community_id, author_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		INSERT INTO posts (title, text, pic_url, target_type, wall_user_id, community_id, author_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
 		RETURNING id, created_at, updated_at
 	`
 
-	err := DB.QueryRowContext(ctx, query,
-		post.Title,       // $1 - название поста
-		post.Text,        // $2 - содержание поста
-		post.PicURL,      // $3 - ссылка на картинку
-		post.CommunityID, // $4 - ID сообщества (= ID пользователя для профиля)
-		post.AuthorID,    // $5 - ID автора
+	var wallUserID, communityID sql.NullInt64
+	switch post.TargetType {
+	case models.PostTargetUser:
+		post.CommunityID = 0
+		wallUserID = sql.NullInt64{Int64: post.WallUserID, Valid: true}
+	case models.PostTargetCommunity:
+		post.WallUserID = 0
+		communityID = sql.NullInt64{Int64: post.CommunityID, Valid: true}
+	default:
+		return ErrInvalidPostTarget
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query,
+		post.Title,      // $1 - название поста
+		post.Text,       // $2 - содержание поста
+		post.PicURL,     // $3 - ссылка на картинку
+		post.TargetType, // $4 - стена пользователя или сообщество
+		wallUserID,      // $5 - ID владельца стены
+		communityID,     // $6 - ID сообщества
+		post.AuthorID,   // $7 - ID автора
 	).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to create post: %w", err)
 	}
 
+	if err := attachPostMedia(ctx, tx, post); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -47,25 +114,14 @@ func GetPostByID(
 	ctx context.Context,
 	postID int64,
 ) (*models.Post, error) {
-	const query = `
-		SELECT id, title, text, pic_url, community_id, author_id, created_at, updated_at
-		FROM posts
-		WHERE id = $1
+	query := `SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.id = $1
 	`
 
 	post := &models.Post{}
 
-	err := DB.QueryRowContext(ctx, query, postID).Scan(
-		&post.ID,
-		&post.Title,
-		&post.Text,
-		&post.PicURL,
-		&post.CommunityID,
-		&post.AuthorID,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
-
+	err := scanPost(DB.QueryRowContext(ctx, query, postID), post)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrPostNotFound
@@ -76,70 +132,69 @@ func GetPostByID(
 	return post, nil
 }
 
-// GetUserPosts retrieves all posts for a specific user with pagination
-// CommunityID = UserID для постов в профиле
-// Возвращает слайс постов, общее количество постов и ошибку
-func GetUserPosts(
+// GetPosts retrieves posts of a user's wall or a community feed,
+// newest first, one keyset page at a time
+func GetPosts(
 	ctx context.Context,
-	userID int64,
-	limit, offset int,
-) ([]*models.Post, int64, error) {
-	// Получаем общее количество постов пользователя
-	const countQuery = `
-		SELECT COUNT(*)
-		FROM posts
-		WHERE community_id = $1
-	`
+	target models.PostTarget,
+	opts PageOptions,
+) ([]*models.Post, PageInfo, error) {
+	var info PageInfo
 
-	var total int64
-	err := DB.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+	column, err := targetColumn(target)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to count posts: %w", err)
+		return nil, info, err
 	}
 
-	// Получаем посты с учетом пагинации
-	const postsQuery = `
-		SELECT id, title, text, pic_url, community_id, author_id, created_at, updated_at
+	info.Total, err = countTotal(ctx, opts, `
+		SELECT COUNT(*)
 		FROM posts
-		WHERE community_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+		WHERE target_type = $1 AND `+column+` = $2
+	`, target.Type, target.ID)
+	if err != nil {
+		return nil, info, err
+	}
+
+	postsQuery := `SELECT ` + postColumns + `
+		FROM posts p
+		WHERE p.target_type = $1 AND p.` + column + ` = $2
+		AND ($3::timestamp IS NULL OR (p.created_at, p.id) < ($3::timestamp, $4::bigint))
+		ORDER BY p.created_at DESC, p.id DESC
+		LIMIT $5
 	`
 
-	rows, err := DB.QueryContext(ctx, postsQuery, userID, limit, offset)
+	afterTime, afterID := cursorArgs(opts.After)
+
+	// Запрашиваем на один пост больше, чтобы понять, есть ли следующая страница
+	rows, err := DB.QueryContext(ctx, postsQuery, target.Type, target.ID, afterTime, afterID, opts.Limit+1)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to fetch posts: %w", err)
+		return nil, info, fmt.Errorf("failed to fetch posts: %w", err)
 	}
 	defer rows.Close()
 
-	posts := make([]*models.Post, 0, limit)
+	posts := make([]*models.Post, 0, opts.Limit)
 
 	for rows.Next() {
 		post := &models.Post{}
 
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Text,
-			&post.PicURL,
-			&post.CommunityID,
-			&post.AuthorID,
-			&post.CreatedAt,
-			&post.UpdatedAt,
-		)
-
-		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan post: %w", err)
+		if err := scanPost(rows, post); err != nil {
+			return nil, info, fmt.Errorf("failed to scan post: %w", err)
 		}
 
 		posts = append(posts, post)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("row iteration error: %w", err)
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
 	}
 
-	return posts, total, nil
+	posts, info.NextCursor = trimPage(posts, opts.Limit, postCursor)
+
+	return posts, info, nil
+}
+
+func postCursor(p *models.Post) Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
 }
 
 // UpdatePost updates an existing post
@@ -194,50 +249,70 @@ func DeletePost(ctx context.Context, postID int64) error {
 	return nil
 }
 
-// LikePost adds a like to a post
-// Возвращает ошибку ErrAlreadyLiked если пользователь уже лайкнул этот пост
-func LikePost(ctx context.Context, postID, userID int64) error {
-	const query = `
-		INSERT INTO post_likes (post_id, user_id, created_at)
-		VALUES ($1, $2, NOW())
-	`
-
-	_, err := DB.ExecContext(ctx, query, postID, userID)
-	if err != nil {
-		// Проверяем на нарушение уникальности (уже существует лайк)
-		if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
-			return ErrAlreadyLiked
-		}
-		return fmt.Errorf("failed to like post: %w", err)
+// LoadPostStats fills the aggregates of a page of posts in one round trip:
+// like_count (reactions of any kind), comment_count (same rule as
+// GetCommentCount), per-kind reactions and the viewer's own reaction.
+// viewerID 0 means an anonymous viewer.
+func LoadPostStats(ctx context.Context, posts []*models.Post, viewerID int64) error {
+	if len(posts) == 0 {
+		return nil
 	}
 
-	return nil
-}
+	byID := make(map[int64]*models.Post, len(posts))
+	ids := make([]int64, 0, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+		ids = append(ids, post.ID)
+	}
 
-// UnlikePost removes a like from a post
-// Возвращает ошибку ErrNotLiked если пользователь не лайкал этот пост
-func UnlikePost(
-	ctx context.Context,
-	postID, userID int64,
-) error {
 	const query = `
-		DELETE FROM post_likes
-		WHERE post_id = $1 AND user_id = $2
+		SELECT p.id,
+			(SELECT COUNT(*) FROM comments cm WHERE cm.post_id = p.id AND cm.deleted_at IS NULL),
+			COALESCE((
+				SELECT jsonb_object_agg(r.reaction, r.cnt)
+				FROM (
+					SELECT reaction, COUNT(*) AS cnt FROM post_reactions pr
+					WHERE pr.post_id = p.id
+					GROUP BY reaction
+				) r
+			), '{}'::jsonb),
+			COALESCE((
+				SELECT reaction::text FROM post_reactions pr
+				WHERE pr.post_id = p.id AND pr.user_id = $2
+			), '')
+		FROM unnest($1::bigint[]) AS p(id)
 	`
 
-	result, err := DB.ExecContext(ctx, query, postID, userID)
+	rows, err := DB.QueryContext(ctx, query, pq.Array(ids), viewerID)
 	if err != nil {
-		return fmt.Errorf("failed to unlike post: %w", err)
+		return fmt.Errorf("failed to fetch post stats: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
-	}
+	for rows.Next() {
+		var postID int64
+		var commentCount int64
+		var reactionsJSON []byte
+		var myReaction string
 
-	if rowsAffected == 0 {
-		return ErrNotLiked
+		if err := rows.Scan(&postID, &commentCount, &reactionsJSON, &myReaction); err != nil {
+			return fmt.Errorf("failed to scan post stats: %w", err)
+		}
+
+		post := byID[postID]
+		post.CommentCount = commentCount
+		post.Reactions = map[string]int64{}
+		if err := json.Unmarshal(reactionsJSON, &post.Reactions); err != nil {
+			return fmt.Errorf("failed to decode post reactions: %w", err)
+		}
+
+		post.LikeCount = 0
+		for _, count := range post.Reactions {
+			post.LikeCount += count
+		}
+		post.MyReaction = myReaction
+		post.LikedByMe = myReaction != ""
 	}
 
-	return nil
+	return rows.Err()
 }
@@ -1,14 +1,19 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/models"
 )
 
 // Errors for user profile operations
@@ -18,12 +23,13 @@ var (
 	ErrUnauthorized  = errors.New("unauthorized - can only edit your own profile")
 )
 
-// UpdateUserProfileRequest - JSON структура для обновления профиля
+// UpdateUserProfileRequest - JSON структура для обновления профиля.
+// Аватар меняется только загрузкой файла (PUT /api/user/avatar), поэтому
+// avatar_url здесь нет.
 type UpdateUserProfileRequest struct {
-	Username  string `json:"username" binding:"max=255"`
-	Email     string `json:"email" binding:"max=255"`
-	Bio       string `json:"bio" binding:"max=500"`
-	AvatarURL string `json:"avatar_url" binding:"max=500"`
+	Username string `json:"username" binding:"max=255"`
+	Email    string `json:"email" binding:"max=255"`
+	Bio      string `json:"bio" binding:"max=500"`
 }
 
 // UserProfileResponse - расширенный ответ с информацией о профиле
@@ -42,6 +48,7 @@ type UserStatsResponse struct {
 	PostCount      int   `json:"post_count"`
 	FriendsCount   int   `json:"friends_count"`
 	FollowersCount int   `json:"followers_count"`
+	FollowingCount int   `json:"following_count"`
 }
 
 // UserPublicResponse - публичный профиль (без email)
@@ -52,25 +59,102 @@ type UserPublicResponse struct {
 	AvatarURL string `json:"avatar_url"`
 }
 
-// GetUserProfile - получить профиль пользователя (публичная информация)
-// GET /api/users/:userID
-// Не требует авторизацию
+// PublicProfileResponse - страница пользователя: публичный профиль,
+// дата регистрации и статистика
+type PublicProfileResponse struct {
+	UserPublicResponse
+	CreatedAt time.Time         `json:"created_at"`
+	Stats     UserStatsResponse `json:"stats"`
+}
+
+// PrivateProfileResponse - полный профиль текущего пользователя со статистикой
+type PrivateProfileResponse struct {
+	UserProfileResponse
+	Stats UserStatsResponse `json:"stats"`
+}
+
+// GetPublicProfile returns the public part of a user's profile
+func GetPublicProfile(ctx context.Context, userID int64) (*PublicProfileResponse, error) {
+	var p PublicProfileResponse
+	err := DB.QueryRowContext(ctx, `
+		SELECT id, username, COALESCE(bio, ''), COALESCE(avatar_url, ''), created_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&p.ID, &p.Username, &p.Bio, &p.AvatarURL, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &p, nil
+}
+
+// GetPrivateProfile returns the full profile of a user, including email
+func GetPrivateProfile(ctx context.Context, userID int64) (*UserProfileResponse, error) {
+	var p UserProfileResponse
+	err := DB.QueryRowContext(ctx, `
+		SELECT id, username, COALESCE(email, ''), COALESCE(bio, ''), COALESCE(avatar_url, ''), created_at
+		FROM users WHERE id = $1
+	`, userID).Scan(&p.ID, &p.Username, &p.Email, &p.Bio, &p.AvatarURL, &p.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+	return &p, nil
+}
+
+// GetUserStats counts the posts on a user's wall, their accepted friends,
+// their followers and the users they follow
+func GetUserStats(ctx context.Context, userID int64) (*UserStatsResponse, error) {
+	stats := UserStatsResponse{UserID: userID}
+	err := DB.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM posts
+			 WHERE target_type = 'user' AND wall_user_id = $1),
+			(SELECT COUNT(*) FROM friendships
+			 WHERE status = $2 AND (user_id = $1 OR friend_id = $1)),
+			(SELECT COUNT(*) FROM follows WHERE followee_id = $1),
+			(SELECT COUNT(*) FROM follows WHERE follower_id = $1)
+	`, userID, models.FriendshipAccepted,
+	).Scan(&stats.PostCount, &stats.FriendsCount, &stats.FollowersCount, &stats.FollowingCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// GetUserProfile - страница пользователя: публичный профиль и статистика
+// GET /user/:userID
+// Не требует авторизацию. Профиль публичный: блокировка скрывает его только
+// от аккаунта заблокированного (403), анонимно он виден всем - как и стена,
+// см. checkWallAccess
 func GetUserProfile(c *gin.Context) {
-	userIDParam := c.Param("userID")
-	userID, err := strconv.ParseInt(userIDParam, 10, 64)
+	userID, err := strconv.ParseInt(c.Param("userID"), 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
 		return
 	}
 
-	var user UserPublicResponse
-	err = DB.QueryRow(
-		`SELECT id, username, bio, avatar_url FROM users WHERE id = $1`,
-		userID,
-	).Scan(&user.ID, &user.Username, &user.Bio, &user.AvatarURL)
+	ctx := c.Request.Context()
+
+	if viewerID := identity.UserID(c); viewerID != 0 && viewerID != userID {
+		blocked, err := HasBlocked(ctx, userID, viewerID)
+		if err != nil {
+			zap.S().Errorf("Failed to check block: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this user's profile is hidden from your account"})
+			return
+		}
+	}
 
+	profile, err := GetPublicProfile(ctx, userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, ErrUserNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
@@ -79,15 +163,56 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	stats, err := GetUserStats(ctx, userID)
+	if err != nil {
+		zap.S().Errorf("Failed to fetch user stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return
+	}
+	profile.Stats = *stats
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// GetMyProfile - полный профиль текущего пользователя (с email) и статистика
+// GET /api/me
+// Требует авторизацию
+func GetMyProfile(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := GetPrivateProfile(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		zap.S().Errorf("Failed to fetch user: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return
+	}
+
+	stats, err := GetUserStats(ctx, userID)
+	if err != nil {
+		zap.S().Errorf("Failed to fetch user stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, PrivateProfileResponse{UserProfileResponse: *user, Stats: *stats})
 }
 
 // UpdateProfile - обновить профиль пользователя
 // PUT /api/me/profile
 // Требует авторизацию
 func UpdateProfile(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -99,16 +224,9 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	// Получаем текущие данные
-	var user UserProfileResponse
-	var email sql.NullString
-
-	err := DB.QueryRow(
-		`SELECT id, username, email, bio, avatar_url, created_at FROM users WHERE id = $1`,
-		userID.(int64),
-	).Scan(&user.ID, &user.Username, &email, &user.Bio, &user.AvatarURL, &user.CreatedAt)
-
+	user, err := GetPrivateProfile(c.Request.Context(), userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, ErrUserNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
@@ -117,10 +235,6 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
-	if email.Valid {
-		user.Email = email.String
-	}
-
 	// Обновляем только переданные поля
 	if req.Username != "" {
 		user.Username = req.Username
@@ -131,18 +245,14 @@ func UpdateProfile(c *gin.Context) {
 	if req.Bio != "" {
 		user.Bio = req.Bio
 	}
-	if req.AvatarURL != "" {
-		user.AvatarURL = req.AvatarURL
-	}
 
 	// Обновляем в БД
 	_, err = DB.Exec(
-		`UPDATE users SET username = $1, email = $2, bio = $3, avatar_url = $4 WHERE id = $5`,
+		`UPDATE users SET username = $1, email = $2, bio = $3 WHERE id = $4`,
 		user.Username,
 		user.Email,
 		user.Bio,
-		user.AvatarURL,
-		userID.(int64),
+		userID,
 	)
 
 	if err != nil {
@@ -173,15 +283,21 @@ func SearchUsers(c *gin.Context) {
 		return
 	}
 
+	// Пользователи, с которыми есть блокировка в любую сторону, не показываются
 	searchQuery := `
-	SELECT id, username, bio, avatar_url
-	FROM users
-	WHERE username ILIKE $1
-	ORDER BY username
+	SELECT u.id, u.username, COALESCE(u.bio, ''), COALESCE(u.avatar_url, '')
+	FROM users u
+	WHERE u.username ILIKE $1
+	AND NOT EXISTS (
+		SELECT 1 FROM friendships f
+		WHERE f.status = 'blocked'
+		AND ((f.user_id = $2 AND f.friend_id = u.id) OR (f.user_id = u.id AND f.friend_id = $2))
+	)
+	ORDER BY u.username
 	LIMIT 20
 	`
 
-	rows, err := DB.Query(searchQuery, "%"+query+"%")
+	rows, err := DB.Query(searchQuery, "%"+query+"%", identity.UserID(c))
 	if err != nil {
 		zap.S().Errorf("Failed to search users: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search users"})
@@ -0,0 +1,107 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// CommunityRecommendation - сообщество, рекомендованное по пересечению подписчиков
+type CommunityRecommendation struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	IsPrivate   bool    `json:"is_private"`
+	Subscribers int64   `json:"subscribers"`
+	Score       float64 `json:"score"`
+	Reason      string  `json:"reason"`
+	// Подписанное сообщество, сильнее всего пересекающееся с рекомендованным
+	Because struct {
+		ID     int64  `json:"id"`
+		Name   string `json:"name"`
+		Common int64  `json:"common_subscribers"`
+	} `json:"because"`
+}
+
+// GetRecommendedCommunities ranks communities the user neither follows nor
+// asked to join. The score of a candidate is the sum of Jaccard similarities
+// (common subscribers / union of subscribers) with every community the user
+// follows, read from the community_overlap table that GetGraphData draws.
+func GetRecommendedCommunities(ctx context.Context, userID int64, limit int) ([]CommunityRecommendation, error) {
+	const query = `
+		WITH mine AS (
+			SELECT community_id FROM community_subscriptions WHERE user_id = $1
+		), excluded AS (
+			SELECT community_id FROM mine
+			UNION
+			SELECT community_id FROM community_join_requests WHERE user_id = $1
+		), pairs AS (
+			SELECT m.community_id AS followed,
+				CASE WHEN o.community_a = m.community_id THEN o.community_b ELSE o.community_a END AS candidate,
+				o.common
+			FROM mine m
+			JOIN community_overlap o ON o.community_a = m.community_id OR o.community_b = m.community_id
+		), overlap AS (
+			SELECT followed, candidate, common FROM pairs
+			WHERE candidate NOT IN (SELECT community_id FROM excluded)
+		), sizes AS (
+			SELECT community_id, COUNT(*) AS size
+			FROM community_subscriptions
+			WHERE community_id IN (SELECT followed FROM overlap UNION SELECT candidate FROM overlap)
+			GROUP BY community_id
+		), scored AS (
+			SELECT o.followed, o.candidate, o.common,
+				o.common::float8 / (sf.size + sc.size - o.common) AS jaccard
+			FROM overlap o
+			JOIN sizes sf ON sf.community_id = o.followed
+			JOIN sizes sc ON sc.community_id = o.candidate
+		), ranked AS (
+			SELECT candidate, SUM(jaccard) AS score
+			FROM scored
+			GROUP BY candidate
+			ORDER BY score DESC, candidate
+			LIMIT $2
+		)
+		SELECT c.id, c.name, COALESCE(c.description, ''), c.is_private, sc.size, r.score,
+			best.followed, fc.name, best.common
+		FROM ranked r
+		JOIN communities c ON c.id = r.candidate
+		JOIN sizes sc ON sc.community_id = r.candidate
+		JOIN LATERAL (
+			SELECT followed, common FROM scored s
+			WHERE s.candidate = r.candidate
+			ORDER BY common DESC, jaccard DESC, followed
+			LIMIT 1
+		) best ON TRUE
+		JOIN communities fc ON fc.id = best.followed
+		ORDER BY r.score DESC, c.id
+	`
+
+	rows, err := DB.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query community recommendations: %w", err)
+	}
+	defer rows.Close()
+
+	recommendations := make([]CommunityRecommendation, 0, limit)
+	for rows.Next() {
+		var r CommunityRecommendation
+		err := rows.Scan(
+			&r.ID,
+			&r.Name,
+			&r.Description,
+			&r.IsPrivate,
+			&r.Subscribers,
+			&r.Score,
+			&r.Because.ID,
+			&r.Because.Name,
+			&r.Because.Common,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan community recommendation: %w", err)
+		}
+		r.Reason = fmt.Sprintf("%d members of %s are here", r.Because.Common, r.Because.Name)
+		recommendations = append(recommendations, r)
+	}
+
+	return recommendations, rows.Err()
+}
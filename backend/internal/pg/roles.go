@@ -0,0 +1,159 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrRoleExists  = errors.New("user already has this role")
+	ErrRoleMissing = errors.New("user does not have this role")
+	ErrLastAdmin   = errors.New("community must keep at least one admin")
+)
+
+// roleTables maps assignable roles to the tables that store them
+var roleTables = map[string]string{
+	models.RoleAdmin:  "community_admin",
+	models.RoleWriter: "community_writer",
+}
+
+// GetCommunityRole resolves the user's highest role in a community.
+// Returns an empty string if the user is neither an admin, a writer nor a subscriber.
+func GetCommunityRole(ctx context.Context, userID, communityID int64) (string, error) {
+	const query = `
+		SELECT CASE
+			WHEN EXISTS(SELECT 1 FROM community_admin WHERE user_id = $1 AND community_id = $2) THEN 'admin'
+			WHEN EXISTS(SELECT 1 FROM community_writer WHERE user_id = $1 AND community_id = $2) THEN 'writer'
+			WHEN EXISTS(SELECT 1 FROM community_subscriptions WHERE user_id = $1 AND community_id = $2) THEN 'subscriber'
+			ELSE ''
+		END
+	`
+
+	var role string
+	err := DB.QueryRowContext(ctx, query, userID, communityID).Scan(&role)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve community role: %w", err)
+	}
+
+	return role, nil
+}
+
+// GetCommunityRoles returns IDs of admins and writers of a community
+func GetCommunityRoles(ctx context.Context, communityID int64) (admins, writers []int64, err error) {
+	admins, err = getRoleMembers(ctx, "community_admin", communityID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	writers, err = getRoleMembers(ctx, "community_writer", communityID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return admins, writers, nil
+}
+
+func getRoleMembers(ctx context.Context, table string, communityID int64) ([]int64, error) {
+	query := fmt.Sprintf(`SELECT user_id FROM %s WHERE community_id = $1 ORDER BY user_id`, table)
+
+	rows, err := DB.QueryContext(ctx, query, communityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan %s row: %w", table, err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GrantCommunityRole gives a user the admin or writer role in a community
+func GrantCommunityRole(ctx context.Context, communityID, userID int64, role string) error {
+	table, ok := roleTables[role]
+	if !ok {
+		return ErrInvalidRole
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, community_id) VALUES ($1, $2)`, table)
+
+	_, err := DB.ExecContext(ctx, query, userID, communityID)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok {
+			switch err.Code {
+			case "23505":
+				return ErrRoleExists
+			case "23503":
+				return ErrUserNotFound
+			}
+		}
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeCommunityRole removes the admin or writer role from a user.
+// The last admin of a community cannot be revoked.
+func RevokeCommunityRole(ctx context.Context, communityID, userID int64, role string) error {
+	table, ok := roleTables[role]
+	if !ok {
+		return ErrInvalidRole
+	}
+
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if role == models.RoleAdmin {
+		// Блокируем строки админов, чтобы два параллельных отзыва не оставили сообщество без админа.
+		// Сначала проверяем, что пользователь вообще админ, и только потом - что он не последний.
+		var admins int
+		var isAdmin bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), COALESCE(BOOL_OR(user_id = $2), FALSE) FROM (
+				SELECT user_id FROM community_admin WHERE community_id = $1 FOR UPDATE
+			) AS a`, communityID, userID).Scan(&admins, &isAdmin)
+		if err != nil {
+			return fmt.Errorf("failed to count admins: %w", err)
+		}
+		if !isAdmin {
+			return ErrRoleMissing
+		}
+		if admins <= 1 {
+			return ErrLastAdmin
+		}
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE user_id = $1 AND community_id = $2`, table)
+
+	result, err := tx.ExecContext(ctx, query, userID, communityID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrRoleMissing
+	}
+
+	return tx.Commit()
+}
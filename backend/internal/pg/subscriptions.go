@@ -0,0 +1,361 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"main/internal/models"
+)
+
+var (
+	ErrAlreadySubscribed   = errors.New("already subscribed")
+	ErrNotSubscribed       = errors.New("not subscribed")
+	ErrJoinRequestExists   = errors.New("join request already pending")
+	ErrJoinRequestNotFound = errors.New("join request not found")
+)
+
+// Subscription statuses returned to the client
+const (
+	SubscriptionActive  = "subscribed"
+	SubscriptionPending = "pending"
+)
+
+// JoinRequest - заявка на вступление в приватное сообщество
+type JoinRequest struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	AvatarURL   string    `json:"avatar_url"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// SubscribeToCommunity subscribes a user to a community.
+// For private communities a pending join request is created instead and
+// SubscriptionPending is returned.
+func SubscribeToCommunity(ctx context.Context, userID, communityID int64) (string, error) {
+	community, err := GetCommunity(ctx, communityID)
+	if err != nil {
+		return "", err
+	}
+
+	subscribed, err := IsSubscribed(ctx, userID, communityID)
+	if err != nil {
+		return "", err
+	}
+	if subscribed {
+		return "", ErrAlreadySubscribed
+	}
+
+	if community.IsPrivate {
+		const joinQuery = `
+			INSERT INTO community_join_requests (user_id, community_id, created_at)
+			VALUES ($1, $2, NOW())
+		`
+		_, err := DB.ExecContext(ctx, joinQuery, userID, communityID)
+		if err != nil {
+			if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+				return "", ErrJoinRequestExists
+			}
+			return "", fmt.Errorf("failed to create join request: %w", err)
+		}
+		return SubscriptionPending, nil
+	}
+
+	const query = `
+		INSERT INTO community_subscriptions (user_id, community_id)
+		VALUES ($1, $2)
+	`
+	_, err = DB.ExecContext(ctx, query, userID, communityID)
+	if err != nil {
+		// Проверяем на нарушение уникальности (уже подписан)
+		if err, ok := err.(*pq.Error); ok && err.Code == "23505" {
+			return "", ErrAlreadySubscribed
+		}
+		return "", fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	return SubscriptionActive, nil
+}
+
+// UnsubscribeFromCommunity removes a subscription or withdraws a pending join request
+// Возвращает ошибку ErrNotSubscribed если не было ни подписки, ни заявки
+func UnsubscribeFromCommunity(ctx context.Context, userID, communityID int64) error {
+	const query = `
+		WITH subs AS (
+			DELETE FROM community_subscriptions
+			WHERE user_id = $1 AND community_id = $2
+			RETURNING 1
+		), reqs AS (
+			DELETE FROM community_join_requests
+			WHERE user_id = $1 AND community_id = $2
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM subs) + (SELECT COUNT(*) FROM reqs)
+	`
+
+	var removed int64
+	err := DB.QueryRowContext(ctx, query, userID, communityID).Scan(&removed)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", err)
+	}
+
+	if removed == 0 {
+		return ErrNotSubscribed
+	}
+
+	return nil
+}
+
+// IsSubscribed reports whether the user is subscribed to the community
+func IsSubscribed(ctx context.Context, userID, communityID int64) (bool, error) {
+	const query = `
+		SELECT EXISTS(
+			SELECT 1 FROM community_subscriptions
+			WHERE user_id = $1 AND community_id = $2
+		)
+	`
+
+	var exists bool
+	err := DB.QueryRowContext(ctx, query, userID, communityID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check subscription: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetUserCommunities retrieves all communities the user is subscribed to
+func GetUserCommunities(ctx context.Context, userID int64) ([]models.Community, error) {
+	const query = `
+		SELECT c.id, c.name, COALESCE(c.description, ''), COALESCE(c.is_private, FALSE), c.created_by, c.created_at
+		FROM community_subscriptions cs
+		JOIN communities c ON c.id = cs.community_id
+		WHERE cs.user_id = $1
+		ORDER BY c.name
+	`
+
+	rows, err := DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch communities: %w", err)
+	}
+	defer rows.Close()
+
+	communities := make([]models.Community, 0)
+
+	for rows.Next() {
+		var community models.Community
+
+		err := rows.Scan(
+			&community.ID,
+			&community.Name,
+			&community.Description,
+			&community.IsPrivate,
+			&community.CreatedBy,
+			&community.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan community: %w", err)
+		}
+
+		communities = append(communities, community)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return communities, nil
+}
+
+// GetCommunitySubscribers lists active subscribers of a community in the
+// order they subscribed, one keyset page at a time.
+// The cursor is built from the subscription time, not the user's.
+// Список публичный, поэтому отдаём только публичные поля профиля.
+func GetCommunitySubscribers(
+	ctx context.Context,
+	communityID int64,
+	opts PageOptions,
+) ([]UserPublicResponse, PageInfo, error) {
+	var info PageInfo
+
+	var exists bool
+	err := DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM communities WHERE id = $1)`, communityID).Scan(&exists)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to check community: %w", err)
+	}
+	if !exists {
+		return nil, info, ErrCommunityNotFound
+	}
+
+	info.Total, err = countTotal(ctx, opts,
+		`SELECT COUNT(*) FROM community_subscriptions WHERE community_id = $1`, communityID)
+	if err != nil {
+		return nil, info, err
+	}
+
+	const query = `
+		SELECT u.id, u.username, COALESCE(u.bio, ''), COALESCE(u.avatar_url, ''),
+			cs.created_at, cs.id
+		FROM community_subscriptions cs
+		JOIN users u ON cs.user_id = u.id
+		WHERE cs.community_id = $1
+		AND ($2::timestamp IS NULL OR (cs.created_at, cs.id) > ($2::timestamp, $3::bigint))
+		ORDER BY cs.created_at ASC, cs.id ASC
+		LIMIT $4
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, communityID, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	type subscriber struct {
+		user UserPublicResponse
+		pos  Cursor
+	}
+
+	page := make([]subscriber, 0, opts.Limit)
+	for rows.Next() {
+		var s subscriber
+		err := rows.Scan(
+			&s.user.ID,
+			&s.user.Username,
+			&s.user.Bio,
+			&s.user.AvatarURL,
+			&s.pos.CreatedAt,
+			&s.pos.ID,
+		)
+		if err != nil {
+			return nil, info, fmt.Errorf("failed to scan subscriber: %w", err)
+		}
+		page = append(page, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	page, info.NextCursor = trimPage(page, opts.Limit, func(s subscriber) Cursor { return s.pos })
+
+	subscribers := make([]UserPublicResponse, len(page))
+	for i, s := range page {
+		subscribers[i] = s.user
+	}
+
+	return subscribers, info, nil
+}
+
+// GetJoinRequests lists pending join requests of a community, oldest first
+func GetJoinRequests(ctx context.Context, communityID int64, opts PageOptions) ([]JoinRequest, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts,
+		`SELECT COUNT(*) FROM community_join_requests WHERE community_id = $1`, communityID)
+	if err != nil {
+		return nil, info, err
+	}
+
+	const query = `
+		SELECT r.id, r.user_id, u.username, COALESCE(u.avatar_url, ''), r.created_at
+		FROM community_join_requests r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.community_id = $1
+		AND ($2::timestamp IS NULL OR (r.created_at, r.id) > ($2::timestamp, $3::bigint))
+		ORDER BY r.created_at ASC, r.id ASC
+		LIMIT $4
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, communityID, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch join requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]JoinRequest, 0, opts.Limit)
+	for rows.Next() {
+		var r JoinRequest
+		if err := rows.Scan(&r.ID, &r.UserID, &r.Username, &r.AvatarURL, &r.RequestedAt); err != nil {
+			return nil, info, fmt.Errorf("failed to scan join request: %w", err)
+		}
+		requests = append(requests, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	requests, info.NextCursor = trimPage(requests, opts.Limit, func(r JoinRequest) Cursor {
+		return Cursor{CreatedAt: r.RequestedAt, ID: r.ID}
+	})
+
+	return requests, info, nil
+}
+
+// ApproveJoinRequest turns a pending join request into a subscription and
+// returns the ID of the user who sent it
+func ApproveJoinRequest(ctx context.Context, communityID, requestID int64) (int64, error) {
+	tx, err := DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM community_join_requests
+		WHERE id = $1 AND community_id = $2
+		RETURNING user_id
+	`, requestID, communityID).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrJoinRequestNotFound
+		}
+		return 0, fmt.Errorf("failed to approve join request: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO community_subscriptions (user_id, community_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, userID, communityID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// RejectJoinRequest deletes a pending join request; the user may ask again
+func RejectJoinRequest(ctx context.Context, communityID, requestID int64) error {
+	result, err := DB.ExecContext(ctx,
+		`DELETE FROM community_join_requests WHERE id = $1 AND community_id = $2`,
+		requestID, communityID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reject join request: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrJoinRequestNotFound
+	}
+
+	return nil
+}
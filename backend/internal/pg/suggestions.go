@@ -0,0 +1,122 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+)
+
+// FriendSuggestion - кандидат в друзья с причинами рекомендации
+type FriendSuggestion struct {
+	ID                int64  `json:"id"`
+	Username          string `json:"username"`
+	AvatarURL         string `json:"avatar_url"`
+	MutualFriends     int64  `json:"mutual_friends"`
+	SharedCommunities int64  `json:"shared_communities"`
+	Score             int64  `json:"score"`
+}
+
+// Вес общего друга относительно общего сообщества
+const mutualFriendWeight = 3
+
+// GetMutualFriends lists accepted friends userID and otherID have in common
+func GetMutualFriends(ctx context.Context, userID, otherID int64) ([]FriendUser, error) {
+	const query = `
+		WITH a AS (
+			SELECT friend_id AS id FROM friendships WHERE user_id = $1 AND status = 'accepted'
+			UNION
+			SELECT user_id AS id FROM friendships WHERE friend_id = $1 AND status = 'accepted'
+		), b AS (
+			SELECT friend_id AS id FROM friendships WHERE user_id = $2 AND status = 'accepted'
+			UNION
+			SELECT user_id AS id FROM friendships WHERE friend_id = $2 AND status = 'accepted'
+		)
+		SELECT u.id, u.username
+		FROM users u
+		JOIN a ON a.id = u.id
+		JOIN b ON b.id = u.id
+		ORDER BY u.username
+	`
+
+	rows, err := DB.QueryContext(ctx, query, userID, otherID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mutual friends: %w", err)
+	}
+	defer rows.Close()
+
+	mutual := make([]FriendUser, 0)
+	for rows.Next() {
+		var friend FriendUser
+		if err := rows.Scan(&friend.ID, &friend.Username); err != nil {
+			return nil, fmt.Errorf("failed to scan mutual friend: %w", err)
+		}
+		mutual = append(mutual, friend)
+	}
+
+	return mutual, rows.Err()
+}
+
+// GetFriendSuggestions ranks users who are not yet connected to userID by
+// mutual friends and shared communities. Anyone with a friendship row of any
+// status with userID (friend, pending or rejected request, block in either
+// direction) is excluded.
+func GetFriendSuggestions(ctx context.Context, userID int64, limit int) ([]FriendSuggestion, error) {
+	const query = `
+		WITH my_friends AS (
+			SELECT friend_id AS id FROM friendships WHERE user_id = $1 AND status = 'accepted'
+			UNION
+			SELECT user_id AS id FROM friendships WHERE friend_id = $1 AND status = 'accepted'
+		), connected AS (
+			SELECT friend_id AS id FROM friendships WHERE user_id = $1
+			UNION
+			SELECT user_id AS id FROM friendships WHERE friend_id = $1
+		), fof AS (
+			SELECT id, COUNT(*) AS mutual
+			FROM (
+				SELECT f.friend_id AS id
+				FROM friendships f JOIN my_friends mf ON mf.id = f.user_id
+				WHERE f.status = 'accepted'
+				UNION ALL
+				SELECT f.user_id AS id
+				FROM friendships f JOIN my_friends mf ON mf.id = f.friend_id
+				WHERE f.status = 'accepted'
+			) t
+			GROUP BY id
+		), shared AS (
+			SELECT theirs.user_id AS id, COUNT(*) AS shared
+			FROM community_subscriptions mine
+			JOIN community_subscriptions theirs ON theirs.community_id = mine.community_id
+			WHERE mine.user_id = $1
+			GROUP BY theirs.user_id
+		)
+		SELECT u.id, u.username, COALESCE(u.avatar_url, ''),
+			COALESCE(fof.mutual, 0) AS mutual,
+			COALESCE(shared.shared, 0) AS shared
+		FROM (SELECT id FROM fof UNION SELECT id FROM shared) candidates
+		JOIN users u ON u.id = candidates.id
+		LEFT JOIN fof ON fof.id = candidates.id
+		LEFT JOIN shared ON shared.id = candidates.id
+		WHERE candidates.id <> $1
+		AND candidates.id NOT IN (SELECT id FROM connected)
+		ORDER BY COALESCE(fof.mutual, 0) * $2 + COALESCE(shared.shared, 0) DESC,
+			mutual DESC, u.id
+		LIMIT $3
+	`
+
+	rows, err := DB.QueryContext(ctx, query, userID, mutualFriendWeight, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query friend suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := make([]FriendSuggestion, 0, limit)
+	for rows.Next() {
+		var s FriendSuggestion
+		if err := rows.Scan(&s.ID, &s.Username, &s.AvatarURL, &s.MutualFriends, &s.SharedCommunities); err != nil {
+			return nil, fmt.Errorf("failed to scan friend suggestion: %w", err)
+		}
+		s.Score = s.MutualFriends*mutualFriendWeight + s.SharedCommunities
+		suggestions = append(suggestions, s)
+	}
+
+	return suggestions, rows.Err()
+}
@@ -1,25 +1,25 @@
 package pg
 
 import (
+	"context"
 	"database/sql"
-	"encoding/hex" // Needed for encoding
 	"fmt"
+	"time"
+
+	"main/internal/models"
 )
 
 var DB *sql.DB
 
-func InsertInDB(username, email string, passwordHash, salt []byte) error {
-	// Encode data to hex strings
-	hashHex := hex.EncodeToString(passwordHash)
-	saltHex := hex.EncodeToString(salt)
-
-	// Insert the hex strings as plain text
+// InsertInDB stores a new user. passwordHash is an encoded string produced by
+// the password package, so the legacy salt column is left empty. created_at
+// is the registration time shown on the profile page.
+func InsertInDB(username, email, passwordHash string) error {
 	_, err := DB.Exec(
-		"INSERT INTO users (username, email, password_hash, salt) VALUES ($1, $2, $3, $4)",
+		"INSERT INTO users (username, email, password_hash, salt, created_at) VALUES ($1, $2, $3, '', NOW())",
 		username,
 		email,
-		hashHex,
-		saltHex,
+		passwordHash,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert user into database: %w", err)
@@ -28,3 +28,125 @@ func InsertInDB(username, email string, passwordHash, salt []byte) error {
 	return nil
 }
 
+// UpdatePasswordHash replaces a user's password hash and clears the legacy salt
+func UpdatePasswordHash(userID int64, passwordHash string) error {
+	_, err := DB.Exec(
+		"UPDATE users SET password_hash = $1, salt = '' WHERE id = $2",
+		passwordHash,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password hash: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserIdentity loads the username and community roles of a user
+// Возвращает ошибку ErrUserNotFound если пользователь удалён
+func GetUserIdentity(ctx context.Context, userID int64) (string, map[int64]string, error) {
+	var username string
+	err := DB.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1`, userID).Scan(&username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil, ErrUserNotFound
+		}
+		return "", nil, fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	// Для каждого сообщества берём наивысшую роль: admin > writer > subscriber
+	const rolesQuery = `
+		SELECT community_id, MAX(rank) FROM (
+			SELECT community_id, 3 AS rank FROM community_admin WHERE user_id = $1
+			UNION ALL
+			SELECT community_id, 2 AS rank FROM community_writer WHERE user_id = $1
+			UNION ALL
+			SELECT community_id, 1 AS rank FROM community_subscriptions WHERE user_id = $1
+		) AS r
+		GROUP BY community_id
+	`
+
+	rows, err := DB.QueryContext(ctx, rolesQuery, userID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch user roles: %w", err)
+	}
+	defer rows.Close()
+
+	roleNames := map[int]string{
+		1: models.RoleSubscriber,
+		2: models.RoleWriter,
+		3: models.RoleAdmin,
+	}
+
+	roles := make(map[int64]string)
+	for rows.Next() {
+		var communityID int64
+		var rank int
+		if err := rows.Scan(&communityID, &rank); err != nil {
+			return "", nil, fmt.Errorf("failed to scan user role: %w", err)
+		}
+		roles[communityID] = roleNames[rank]
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return username, roles, nil
+}
+
+// ListUsers returns users in registration order, one keyset page at a time.
+// Only public fields (id, username, avatar) are loaded.
+func ListUsers(ctx context.Context, opts PageOptions) ([]models.User, PageInfo, error) {
+	var info PageInfo
+	var err error
+
+	info.Total, err = countTotal(ctx, opts, `SELECT COUNT(*) FROM users`)
+	if err != nil {
+		return nil, info, err
+	}
+
+	const query = `
+		SELECT id, username, COALESCE(avatar_url, ''), created_at
+		FROM users
+		WHERE ($1::timestamp IS NULL OR (created_at, id) > ($1::timestamp, $2::bigint))
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3
+	`
+
+	afterTime, afterID := cursorArgs(opts.After)
+
+	rows, err := DB.QueryContext(ctx, query, afterTime, afterID, opts.Limit+1)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to fetch users: %w", err)
+	}
+	defer rows.Close()
+
+	type listedUser struct {
+		user      models.User
+		createdAt time.Time
+	}
+
+	page := make([]listedUser, 0, opts.Limit)
+	for rows.Next() {
+		var u listedUser
+		if err := rows.Scan(&u.user.ID, &u.user.Username, &u.user.AvatarURL, &u.createdAt); err != nil {
+			return nil, info, fmt.Errorf("failed to scan user: %w", err)
+		}
+		page = append(page, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, info, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	page, info.NextCursor = trimPage(page, opts.Limit, func(u listedUser) Cursor {
+		return Cursor{CreatedAt: u.createdAt, ID: u.user.ID}
+	})
+
+	users := make([]models.User, len(page))
+	for i, u := range page {
+		users[i] = u.user
+	}
+
+	return users, info, nil
+}
@@ -0,0 +1,227 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/imaging"
+	"main/internal/pg"
+	"main/internal/storage"
+)
+
+// Store - хранилище файлов аватаров, задаётся в main
+var Store storage.Storage
+
+// MaxUploadSize - максимальный размер загружаемого файла
+const MaxUploadSize = 5 << 20
+
+// Sizes - стороны квадратных миниатюр в пикселях, по возрастанию
+var Sizes = []int{64, 128, 256, 512}
+
+const defaultSize = 128
+
+// URL is the public address of a user's avatar, stored in users.avatar_url
+func URL(userID int64) string {
+	return fmt.Sprintf("/user/%d/avatar", userID)
+}
+
+func thumbnailKey(a pg.Avatar, size int) string {
+	return fmt.Sprintf("%s/%d%s", a.Key, size, imaging.Extension(a.ContentType))
+}
+
+func thumbnailKeys(a pg.Avatar) []string {
+	keys := make([]string, 0, len(Sizes))
+	for _, size := range Sizes {
+		keys = append(keys, thumbnailKey(a, size))
+	}
+	return keys
+}
+
+// pickSize returns the smallest thumbnail at least as large as requested
+func pickSize(requested int) int {
+	for _, size := range Sizes {
+		if size >= requested {
+			return size
+		}
+	}
+	return Sizes[len(Sizes)-1]
+}
+
+// UploadAvatarHandler replaces the session user's avatar
+// PUT /api/user/avatar (multipart/form-data, поле "avatar")
+// Принимает JPEG, PNG и GIF до 5 МБ; метаданные вырезаются, из центрального
+// квадрата строятся миниатюры всех размеров из Sizes
+func UploadAvatarHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// Запас на заголовки multipart
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxUploadSize+64<<10)
+
+	file, header, err := c.Request.FormFile("avatar")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "avatar must not exceed 5 MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field 'avatar' is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "avatar must not exceed 5 MB"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxUploadSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read avatar"})
+		return
+	}
+	if len(data) > MaxUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "avatar must not exceed 5 MB"})
+		return
+	}
+
+	img, sourceType, err := imaging.Decode(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":   "unsupported image type",
+				"allowed": []string{"image/jpeg", "image/png", "image/gif"},
+			})
+		case errors.Is(err, imaging.ErrTooManyPixels):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image dimensions are too large"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid image"})
+		}
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	avatar := pg.Avatar{
+		Key:         fmt.Sprintf("avatars/%d/%d", userID, time.Now().UnixNano()),
+		ContentType: imaging.OutputType(sourceType),
+	}
+
+	// От большего к меньшему: каждая миниатюра считается из предыдущей
+	thumb := imaging.Square(img)
+	for i := len(Sizes) - 1; i >= 0; i-- {
+		size := Sizes[i]
+		thumb = imaging.Resize(thumb, size, size)
+
+		encoded, err := imaging.EncodeBytes(thumb, avatar.ContentType)
+		if err == nil {
+			err = Store.Put(ctx, thumbnailKey(avatar, size), bytes.NewReader(encoded), avatar.ContentType)
+		}
+		if err != nil {
+			zap.S().Errorw("Failed to store avatar", "error", err, "user_id", userID)
+			if err := Store.Delete(ctx, thumbnailKeys(avatar)...); err != nil {
+				zap.S().Warnw("Failed to clean up avatar", "error", err, "key", avatar.Key)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save avatar"})
+			return
+		}
+	}
+
+	previous, err := pg.SetUserAvatar(ctx, userID, avatar, URL(userID))
+	if err != nil {
+		zap.S().Errorw("Failed to update avatar", "error", err, "user_id", userID)
+		if err := Store.Delete(ctx, thumbnailKeys(avatar)...); err != nil {
+			zap.S().Warnw("Failed to clean up avatar", "error", err, "key", avatar.Key)
+		}
+		if errors.Is(err, pg.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save avatar"})
+		return
+	}
+
+	if previous != nil {
+		if err := Store.Delete(ctx, thumbnailKeys(*previous)...); err != nil {
+			zap.S().Warnw("Failed to delete previous avatar", "error", err, "key", previous.Key)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "avatar updated successfully",
+		"avatar_url": URL(userID),
+		"sizes":      Sizes,
+	})
+}
+
+// GetAvatarHandler serves a user's avatar thumbnail
+// GET /user/:userID/avatar?size=128
+// Отдаётся наименьшая миниатюра не меньше запрошенного размера
+func GetAvatarHandler(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("userID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	size := defaultSize
+	if s := c.Query("size"); s != "" {
+		requested, err := strconv.Atoi(s)
+		if err != nil || requested < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be a positive integer", "sizes": Sizes})
+			return
+		}
+		size = pickSize(requested)
+	}
+
+	ctx := c.Request.Context()
+
+	avatar, err := pg.GetUserAvatar(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pg.ErrUserNotFound) || errors.Is(err, pg.ErrNoAvatar) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
+			return
+		}
+		zap.S().Errorw("Failed to fetch avatar", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch avatar"})
+		return
+	}
+
+	// Версия аватара - последний сегмент ключа, при замене он меняется
+	etag := fmt.Sprintf(`"%s-%d"`, path.Base(avatar.Key), size)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	rc, err := Store.Open(ctx, thumbnailKey(*avatar, size))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
+			return
+		}
+		zap.S().Errorw("Failed to open avatar", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch avatar"})
+		return
+	}
+	defer rc.Close()
+
+	c.DataFromReader(http.StatusOK, -1, avatar.ContentType, rc, map[string]string{
+		"ETag":          etag,
+		"Cache-Control": "public, max-age=300",
+	})
+}
@@ -0,0 +1,109 @@
+package blocks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/cache"
+	"main/internal/pagination"
+	"main/internal/pg"
+)
+
+// BlockUserHandler blocks a user
+// POST /api/blocks/:user_id
+// Дружба и заявки между пользователями удаляются
+func BlockUserHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	targetID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+		return
+	}
+
+	err = pg.BlockUser(c.Request.Context(), userID, targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, pg.ErrAlreadyBlocked):
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already blocked"})
+		default:
+			zap.S().Errorw("Failed to block user", "error", err, "user_id", userID, "target_id", targetID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not block user"})
+		}
+		return
+	}
+
+	cache.InvalidateFriendSuggestions(c.Request.Context(), userID, targetID)
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User blocked successfully"})
+}
+
+// UnblockUserHandler removes a block
+// DELETE /api/blocks/:user_id
+func UnblockUserHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	targetID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	err = pg.UnblockUser(c.Request.Context(), userID, targetID)
+	if err != nil {
+		if errors.Is(err, pg.ErrNotBlocked) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+			return
+		}
+		zap.S().Errorw("Failed to unblock user", "error", err, "user_id", userID, "target_id", targetID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not unblock user"})
+		return
+	}
+
+	cache.InvalidateFriendSuggestions(c.Request.Context(), userID, targetID)
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetBlocksHandler lists users blocked by the session user
+// GET /api/blocks?limit=50&cursor=...&total=1
+func GetBlocksHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	blocked, info, err := pg.GetBlockedUsers(c.Request.Context(), userID, opts)
+	if err != nil {
+		zap.S().Errorw("Failed to get blocked users", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("blocks", blocked, opts, info))
+}
@@ -0,0 +1,142 @@
+package follows
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/pagination"
+	"main/internal/pg"
+)
+
+// FollowHandler subscribes the session user to another user's public posts
+// POST /api/users/:id/follow
+// Подписка односторонняя и не требует согласия, в отличие от заявки в друзья
+func FollowHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	if targetID == userID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	err = pg.Follow(c.Request.Context(), userID, targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, pg.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, pg.ErrBlocked):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot follow this user"})
+		case errors.Is(err, pg.ErrAlreadyFollowing):
+			c.JSON(http.StatusConflict, gin.H{"error": "Already following this user"})
+		default:
+			zap.S().Errorw("Failed to follow user", "error", err, "user_id", userID, "target_id", targetID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not follow user"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User followed successfully"})
+}
+
+// UnfollowHandler removes the session user's follow
+// DELETE /api/users/:id/follow
+func UnfollowHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	err = pg.Unfollow(c.Request.Context(), userID, targetID)
+	if err != nil {
+		if errors.Is(err, pg.ErrNotFollowing) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Not following this user"})
+			return
+		}
+		zap.S().Errorw("Failed to unfollow user", "error", err, "user_id", userID, "target_id", targetID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not unfollow user"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetFollowersHandler lists the users following a user
+// GET /api/users/:id/followers?limit=50&cursor=...&total=1
+func GetFollowersHandler(c *gin.Context) {
+	listFollows(c, "followers", pg.GetFollowers)
+}
+
+// GetFollowingHandler lists the users a user follows
+// GET /api/users/:id/following?limit=50&cursor=...&total=1
+func GetFollowingHandler(c *gin.Context) {
+	listFollows(c, "following", pg.GetFollowing)
+}
+
+type listFunc func(ctx context.Context, userID int64, opts pg.PageOptions) ([]pg.FollowUser, pg.PageInfo, error)
+
+// listFollows answers both lists; при блокировке в любую сторону списки
+// пользователя недоступны
+func listFollows(c *gin.Context, key string, list listFunc) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	targetID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if targetID != userID {
+		blocked, err := pg.IsBlockedEither(ctx, userID, targetID)
+		if err != nil {
+			zap.S().Errorw("Failed to check block", "error", err, "user_id", userID, "target_id", targetID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve " + key})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This user's follow lists are not available"})
+			return
+		}
+	}
+
+	users, info, err := list(ctx, targetID, opts)
+	if err != nil {
+		zap.S().Errorw("Failed to get "+key, "error", err, "user_id", userID, "target_id", targetID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve " + key})
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response(key, users, opts, info))
+}
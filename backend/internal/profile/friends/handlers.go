@@ -1,21 +1,26 @@
 package friends
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
-	"github.com/alexedwards/scs/v2"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"main/internal/auth/identity"
+	"main/internal/cache"
 	"main/internal/pg"
 )
 
+// Рекомендаций считаем и кэшируем не больше этого числа
+const maxSuggestions = 50
+
 type FriendRequestPayload struct {
 	FriendID int64 `json:"friend_id"`
 }
 
-func SendFriendRequestHandler(c *gin.Context, sessionManager *scs.SessionManager) {
-	senderID := sessionManager.GetInt64(c.Request.Context(), "userID")
+func SendFriendRequestHandler(c *gin.Context) {
+	senderID := identity.UserID(c)
 	if senderID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -28,17 +33,47 @@ func SendFriendRequestHandler(c *gin.Context, sessionManager *scs.SessionManager
 	}
 
 	err := pg.CreateFriendRequest(senderID, payload.FriendID)
+	if errors.Is(err, pg.ErrSelfFriendRequest) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot send a friend request to yourself"})
+		return
+	}
+	if errors.Is(err, pg.ErrAlreadyFriends) {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already friends with this user"})
+		return
+	}
+	if errors.Is(err, pg.ErrFriendRequestExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A friend request between you and this user is already pending"})
+		return
+	}
+	if errors.Is(err, pg.ErrBlocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot send a friend request to this user"})
+		return
+	}
+	if errors.Is(err, pg.ErrUserNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var cooldown *pg.FriendRequestCooldownError
+	if errors.As(err, &cooldown) {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "Your friend request was rejected recently",
+			"retry_after": cooldown.Until,
+		})
+		return
+	}
 	if err != nil {
 		zap.S().Errorw("Failed to create friend request", "error", err, "sender_id", senderID, "receiver_id", payload.FriendID)
-		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not send friend request"})
 		return
 	}
 
+	cache.InvalidateFriendSuggestions(c.Request.Context(), senderID, payload.FriendID)
+
 	c.JSON(http.StatusCreated, gin.H{"message": "Friend request sent successfully"})
 }
 
-func GetFriendsHandler(c *gin.Context, sessionManager *scs.SessionManager) {
-	userID := sessionManager.GetInt64(c.Request.Context(), "userID")
+func GetFriendsHandler(c *gin.Context) {
+	userID := identity.UserID(c)
 	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -58,8 +93,8 @@ func GetFriendsHandler(c *gin.Context, sessionManager *scs.SessionManager) {
 	c.JSON(http.StatusOK, friends)
 }
 
-func DeleteFriendHandler(c *gin.Context, sessionManager *scs.SessionManager) {
-	userID := sessionManager.GetInt64(c.Request.Context(), "userID")
+func DeleteFriendHandler(c *gin.Context) {
+	userID := identity.UserID(c)
 	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -79,13 +114,15 @@ func DeleteFriendHandler(c *gin.Context, sessionManager *scs.SessionManager) {
 		return
 	}
 
+	cache.InvalidateFriendSuggestions(c.Request.Context(), userID, friendID)
+
 	c.Status(http.StatusNoContent)
 }
 
 // --- New Handlers ---
 
-func GetIncomingRequestsHandler(c *gin.Context, sessionManager *scs.SessionManager) {
-	receiverID := sessionManager.GetInt64(c.Request.Context(), "userID")
+func GetIncomingRequestsHandler(c *gin.Context) {
+	receiverID := identity.UserID(c)
 	if receiverID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -109,8 +146,8 @@ type UpdateRequestPayload struct {
 	Status string `json:"status"`
 }
 
-func UpdateFriendRequestHandler(c *gin.Context, sessionManager *scs.SessionManager) {
-	receiverID := sessionManager.GetInt64(c.Request.Context(), "userID")
+func UpdateFriendRequestHandler(c *gin.Context) {
+	receiverID := identity.UserID(c)
 	if receiverID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
@@ -129,12 +166,151 @@ func UpdateFriendRequestHandler(c *gin.Context, sessionManager *scs.SessionManag
 		return
 	}
 
-	err = pg.UpdateFriendRequestStatus(requestID, receiverID, payload.Status)
+	senderID, err := pg.UpdateFriendRequestStatus(requestID, receiverID, payload.Status)
 	if err != nil {
-		zap.S().Errorw("Failed to update friend request", "error", err, "receiver_id", receiverID, "request_id", requestID)
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, pg.ErrInvalidFriendRequestStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, pg.ErrFriendRequestNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending friend request found with the specified ID"})
+		default:
+			zap.S().Errorw("Failed to update friend request", "error", err, "receiver_id", receiverID, "request_id", requestID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not update friend request"})
+		}
 		return
 	}
 
+	cache.InvalidateFriendSuggestions(c.Request.Context(), receiverID, senderID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Friend request status updated successfully"})
-}
\ No newline at end of file
+}
+
+func GetOutgoingRequestsHandler(c *gin.Context) {
+	senderID := identity.UserID(c)
+	if senderID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requests, err := pg.GetOutgoingFriendRequests(senderID)
+	if err != nil {
+		zap.S().Errorw("Failed to get outgoing friend requests", "error", err, "user_id", senderID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve outgoing friend requests"})
+		return
+	}
+
+	if requests == nil {
+		requests = make([]pg.OutgoingRequest, 0)
+	}
+
+	c.JSON(http.StatusOK, requests)
+}
+
+func CancelFriendRequestHandler(c *gin.Context) {
+	senderID := identity.UserID(c)
+	if senderID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	requestIDStr := c.Param("request_id")
+	requestID, err := strconv.ParseInt(requestIDStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID format"})
+		return
+	}
+
+	receiverID, err := pg.CancelFriendRequest(requestID, senderID)
+	if err != nil {
+		if errors.Is(err, pg.ErrFriendRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending friend request found with the specified ID"})
+			return
+		}
+		zap.S().Errorw("Failed to cancel friend request", "error", err, "sender_id", senderID, "request_id", requestID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not cancel friend request"})
+		return
+	}
+
+	cache.InvalidateFriendSuggestions(c.Request.Context(), senderID, receiverID)
+
+	c.Status(http.StatusNoContent)
+}
+
+func GetMutualFriendsHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	otherID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	blocked, err := pg.IsBlockedEither(c.Request.Context(), userID, otherID)
+	if err != nil {
+		zap.S().Errorw("Failed to check block", "error", err, "user_id", userID, "other_id", otherID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve mutual friends"})
+		return
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Mutual friends with this user are not available"})
+		return
+	}
+
+	mutual, err := pg.GetMutualFriends(c.Request.Context(), userID, otherID)
+	if err != nil {
+		zap.S().Errorw("Failed to get mutual friends", "error", err, "user_id", userID, "other_id", otherID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve mutual friends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, mutual)
+}
+
+// GetSuggestionsHandler returns people the user may know, ranked by mutual
+// friends and shared communities. The ranking is cached in Dragonfly per user.
+func GetSuggestionsHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= maxSuggestions {
+			limit = parsed
+		}
+	}
+
+	ctx := c.Request.Context()
+	key := cache.FriendSuggestionsKey(userID)
+
+	var suggestions []pg.FriendSuggestion
+	found, err := cache.GetJSON(ctx, key, &suggestions)
+	if err != nil {
+		zap.S().Warnw("Failed to read cached friend suggestions", "error", err, "user_id", userID)
+	}
+
+	if !found {
+		suggestions, err = pg.GetFriendSuggestions(ctx, userID, maxSuggestions)
+		if err != nil {
+			zap.S().Errorw("Failed to get friend suggestions", "error", err, "user_id", userID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve friend suggestions"})
+			return
+		}
+
+		if err := cache.SetJSON(ctx, key, suggestions, cache.FriendSuggestionsTTL); err != nil {
+			zap.S().Warnw("Failed to cache friend suggestions", "error", err, "user_id", userID)
+		}
+	}
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
@@ -0,0 +1,265 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"main/internal/pg"
+)
+
+// Ограничения, чтобы запрос по "звёздному" пользователю не обходил всю базу
+const (
+	maxLabelPropagationRounds = 20
+	maxPathVisited            = 20000
+)
+
+var errPathSearchTooWide = errors.New("path search exceeded the visited limit")
+
+// adjacencyFunc loads the friends of every given user, sorted by ID
+type adjacencyFunc func(ctx context.Context, userIDs []int64) (map[int64][]int64, error)
+
+// viewerAdjacency reads friendships as viewerID sees them: пользователи,
+// с которыми у зрителя блокировка, выпадают из сети и путей
+func viewerAdjacency(viewerID int64) adjacencyFunc {
+	return func(ctx context.Context, userIDs []int64) (map[int64][]int64, error) {
+		return pg.GetFriendAdjacency(ctx, viewerID, userIDs)
+	}
+}
+
+// network is the friend graph around a center user
+type network struct {
+	center    int64
+	hops      map[int64]int     // расстояние от центра
+	order     []int64           // вершины в порядке обхода
+	adjacency map[int64][]int64 // только рёбра между вершинами сети
+	truncated bool              // упёрлись в maxNodes
+}
+
+// buildNetwork collects accepted friendships up to maxHops from center with a
+// level-by-level BFS, one query per level. Edges between two vertices of the
+// last level are picked up by one extra adjacency query.
+func buildNetwork(ctx context.Context, adjacent adjacencyFunc, center int64, maxHops, maxNodes int) (*network, error) {
+	n := &network{
+		center:    center,
+		hops:      map[int64]int{center: 0},
+		order:     []int64{center},
+		adjacency: make(map[int64][]int64),
+	}
+
+	seen := make(map[[2]int64]bool)
+	addEdge := func(a, b int64) {
+		key := edgeKey(a, b)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		n.adjacency[a] = append(n.adjacency[a], b)
+		n.adjacency[b] = append(n.adjacency[b], a)
+	}
+
+	frontier := []int64{center}
+	for level := 0; level <= maxHops && len(frontier) > 0; level++ {
+		adjacency, err := adjacent(ctx, frontier)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for _, u := range frontier {
+			for _, v := range adjacency[u] {
+				if _, ok := n.hops[v]; !ok {
+					if level == maxHops {
+						continue
+					}
+					if len(n.order) >= maxNodes {
+						n.truncated = true
+						continue
+					}
+					n.hops[v] = level + 1
+					n.order = append(n.order, v)
+					next = append(next, v)
+				}
+				addEdge(u, v)
+			}
+		}
+		frontier = next
+	}
+
+	for _, neighbors := range n.adjacency {
+		slices.Sort(neighbors)
+	}
+
+	return n, nil
+}
+
+// labelPropagation detects clusters: every vertex repeatedly takes the label
+// with the heaviest vote among its neighbors until nothing changes. Голос
+// соседа весит 1 + число общих друзей (см. edgeWeights), иначе мост между
+// двумя плотными группами перетягивает метку одной группы на другую.
+// Обход в порядке возрастания ID и выбор наименьшей метки при равенстве
+// делают результат детерминированным; текущая метка сохраняется, если она
+// среди лучших.
+func labelPropagation(nodes []int64, adjacency map[int64][]int64) map[int64]int64 {
+	sorted := slices.Clone(nodes)
+	slices.Sort(sorted)
+
+	weights := edgeWeights(adjacency)
+
+	labels := make(map[int64]int64, len(sorted))
+	for _, id := range sorted {
+		labels[id] = id
+	}
+
+	for round := 0; round < maxLabelPropagationRounds; round++ {
+		changed := false
+		for _, id := range sorted {
+			neighbors := adjacency[id]
+			if len(neighbors) == 0 {
+				continue
+			}
+
+			counts := make(map[int64]int, len(neighbors))
+			for _, v := range neighbors {
+				counts[labels[v]] += weights[edgeKey(id, v)]
+			}
+
+			current := labels[id]
+			best, bestCount := current, counts[current]
+			for label, count := range counts {
+				if count > bestCount || (count == bestCount && best != current && label < best) {
+					best, bestCount = label, count
+				}
+			}
+
+			if best != current {
+				labels[id] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	return labels
+}
+
+func edgeKey(a, b int64) [2]int64 {
+	return [2]int64{min(a, b), max(a, b)}
+}
+
+// edgeWeights weighs every edge by 1 + the number of common neighbors of its
+// endpoints: рёбра внутри плотной группы лежат во многих треугольниках,
+// а мост - ни в одном
+func edgeWeights(adjacency map[int64][]int64) map[[2]int64]int {
+	neighborSets := make(map[int64]map[int64]bool, len(adjacency))
+	for id, neighbors := range adjacency {
+		set := make(map[int64]bool, len(neighbors))
+		for _, v := range neighbors {
+			set[v] = true
+		}
+		neighborSets[id] = set
+	}
+
+	weights := make(map[[2]int64]int)
+	for u, neighbors := range adjacency {
+		for _, v := range neighbors {
+			key := edgeKey(u, v)
+			if _, ok := weights[key]; ok {
+				continue
+			}
+
+			small, large := adjacency[u], neighborSets[v]
+			if len(adjacency[v]) < len(small) {
+				small, large = adjacency[v], neighborSets[u]
+			}
+			common := 0
+			for _, w := range small {
+				if large[w] {
+					common++
+				}
+			}
+			weights[key] = 1 + common
+		}
+	}
+
+	return weights
+}
+
+// shortestPath finds a shortest chain of accepted friendships between from
+// and to, at most maxDepth edges long, with a bidirectional BFS that loads
+// neighbors from the database one frontier at a time. Возвращает nil, если
+// пути нет.
+func shortestPath(ctx context.Context, adjacent adjacencyFunc, from, to int64, maxDepth int) ([]int64, error) {
+	if from == to {
+		return []int64{from}, nil
+	}
+
+	forward := map[int64]int64{from: from}
+	backward := map[int64]int64{to: to}
+	forwardFrontier := []int64{from}
+	backwardFrontier := []int64{to}
+
+	for depth := 0; depth < maxDepth; depth++ {
+		if len(forwardFrontier) == 0 || len(backwardFrontier) == 0 {
+			return nil, nil
+		}
+		if len(forward)+len(backward) > maxPathVisited {
+			return nil, errPathSearchTooWide
+		}
+
+		// Расширяем меньший фронт
+		expandForward := len(forwardFrontier) <= len(backwardFrontier)
+		frontier, parents, other := forwardFrontier, forward, backward
+		if !expandForward {
+			frontier, parents, other = backwardFrontier, backward, forward
+		}
+
+		adjacency, err := adjacent(ctx, frontier)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []int64
+		for _, u := range frontier {
+			for _, v := range adjacency[u] {
+				if _, ok := parents[v]; ok {
+					continue
+				}
+				parents[v] = u
+				if _, ok := other[v]; ok {
+					return joinPath(forward, backward, v), nil
+				}
+				next = append(next, v)
+			}
+		}
+
+		if expandForward {
+			forwardFrontier = next
+		} else {
+			backwardFrontier = next
+		}
+	}
+
+	return nil, nil
+}
+
+// joinPath glues the two BFS trees at the meeting vertex
+func joinPath(forward, backward map[int64]int64, meet int64) []int64 {
+	var path []int64
+	for v := meet; ; v = forward[v] {
+		path = append(path, v)
+		if forward[v] == v {
+			break
+		}
+	}
+	slices.Reverse(path)
+
+	for v := meet; backward[v] != v; {
+		v = backward[v]
+		path = append(path, v)
+	}
+
+	return path
+}
@@ -0,0 +1,250 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// friendGraph is an in-memory friend graph standing in for the database
+type friendGraph struct {
+	adjacency map[int64][]int64
+	hidden    map[int64]bool // как заблокированные зрителем: выпадают из соседей
+	calls     int
+}
+
+func newFriendGraph(edges ...[2]int64) *friendGraph {
+	g := &friendGraph{adjacency: make(map[int64][]int64), hidden: make(map[int64]bool)}
+	for _, e := range edges {
+		g.adjacency[e[0]] = append(g.adjacency[e[0]], e[1])
+		g.adjacency[e[1]] = append(g.adjacency[e[1]], e[0])
+	}
+	for _, neighbors := range g.adjacency {
+		slices.Sort(neighbors)
+	}
+	return g
+}
+
+func (g *friendGraph) adjacent(_ context.Context, userIDs []int64) (map[int64][]int64, error) {
+	g.calls++
+	result := make(map[int64][]int64, len(userIDs))
+	for _, id := range userIDs {
+		for _, v := range g.adjacency[id] {
+			if !g.hidden[v] {
+				result[id] = append(result[id], v)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (g *friendGraph) nodes() []int64 {
+	nodes := make([]int64, 0, len(g.adjacency))
+	for id := range g.adjacency {
+		nodes = append(nodes, id)
+	}
+	return nodes
+}
+
+// clique returns the edges of a complete graph on ids
+func clique(ids ...int64) [][2]int64 {
+	var edges [][2]int64
+	for i, a := range ids {
+		for _, b := range ids[i+1:] {
+			edges = append(edges, [2]int64{a, b})
+		}
+	}
+	return edges
+}
+
+// clusters groups vertices by label, each group sorted, groups by first member
+func clusters(labels map[int64]int64) [][]int64 {
+	byLabel := make(map[int64][]int64)
+	for id, label := range labels {
+		byLabel[label] = append(byLabel[label], id)
+	}
+	groups := make([][]int64, 0, len(byLabel))
+	for _, members := range byLabel {
+		slices.Sort(members)
+		groups = append(groups, members)
+	}
+	slices.SortFunc(groups, func(a, b []int64) int { return int(a[0] - b[0]) })
+	return groups
+}
+
+func TestLabelPropagation(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges [][2]int64
+		extra []int64 // вершины без рёбер
+		want  [][]int64
+	}{
+		{
+			name:  "two cliques joined by a bridge",
+			edges: append(append(clique(1, 2, 3, 4), clique(5, 6, 7, 8)...), [2]int64{4, 5}),
+			want:  [][]int64{{1, 2, 3, 4}, {5, 6, 7, 8}},
+		},
+		{
+			name:  "bridge from the lowest vertex",
+			edges: append(append(clique(1, 2, 3, 4, 5), clique(6, 7, 8, 9, 10)...), [2]int64{1, 10}),
+			want:  [][]int64{{1, 2, 3, 4, 5}, {6, 7, 8, 9, 10}},
+		},
+		{
+			name:  "two separate cliques",
+			edges: append(clique(1, 2, 3), clique(4, 5, 6)...),
+			want:  [][]int64{{1, 2, 3}, {4, 5, 6}},
+		},
+		{
+			name:  "path",
+			edges: [][2]int64{{1, 2}, {2, 3}, {3, 4}},
+			want:  [][]int64{{1, 2, 3, 4}},
+		},
+		{
+			name:  "isolated vertex keeps its own label",
+			edges: clique(1, 2, 3),
+			extra: []int64{9},
+			want:  [][]int64{{1, 2, 3}, {9}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFriendGraph(tt.edges...)
+			nodes := append(g.nodes(), tt.extra...)
+
+			labels := labelPropagation(nodes, g.adjacency)
+			got := clusters(labels)
+			if !slices.EqualFunc(got, tt.want, slices.Equal) {
+				t.Errorf("clusters = %v, want %v", got, tt.want)
+			}
+
+			// Порядок входных вершин не влияет на результат
+			slices.Reverse(nodes)
+			again := labelPropagation(nodes, g.adjacency)
+			for id, label := range labels {
+				if again[id] != label {
+					t.Fatalf("label of %d: %d, then %d after reordering", id, label, again[id])
+				}
+			}
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	// 1 дружит с 10, 11, 12, поэтому прямой фронт шире и поиск идёт навстречу
+	// от 5: пути сходятся посередине
+	wide := [][2]int64{{1, 2}, {1, 10}, {1, 11}, {1, 12}, {2, 3}, {3, 4}, {4, 5}}
+	// Два маршрута от 1 до 4; через 2 короче
+	twoRoutes := [][2]int64{{1, 2}, {2, 4}, {1, 3}, {3, 5}, {5, 4}}
+
+	tests := []struct {
+		name     string
+		edges    [][2]int64
+		hidden   []int64
+		from, to int64
+		maxDepth int
+		want     []int64
+	}{
+		{name: "direct friends", edges: wide, from: 1, to: 2, maxDepth: 6, want: []int64{1, 2}},
+		{name: "meets from both sides", edges: wide, from: 1, to: 5, maxDepth: 6, want: []int64{1, 2, 3, 4, 5}},
+		{name: "reverse direction", edges: wide, from: 5, to: 1, maxDepth: 6, want: []int64{5, 4, 3, 2, 1}},
+		{name: "from a leaf", edges: wide, from: 10, to: 4, maxDepth: 6, want: []int64{10, 1, 2, 3, 4}},
+		{name: "beyond max depth", edges: wide, from: 1, to: 5, maxDepth: 3},
+		{name: "exactly max depth", edges: wide, from: 1, to: 5, maxDepth: 4, want: []int64{1, 2, 3, 4, 5}},
+		{name: "not connected", edges: append(wide, [2]int64{20, 21}), from: 1, to: 21, maxDepth: 6},
+		{name: "shortest of two routes", edges: twoRoutes, from: 1, to: 4, maxDepth: 6, want: []int64{1, 2, 4}},
+		{name: "routes around a hidden user", edges: twoRoutes, hidden: []int64{2}, from: 1, to: 4, maxDepth: 6, want: []int64{1, 3, 5, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFriendGraph(tt.edges...)
+			for _, id := range tt.hidden {
+				g.hidden[id] = true
+			}
+
+			got, err := shortestPath(context.Background(), g.adjacent, tt.from, tt.to, tt.maxDepth)
+			if err != nil {
+				t.Fatalf("shortestPath: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("path = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestPathSameUser(t *testing.T) {
+	g := newFriendGraph([2]int64{1, 2})
+
+	got, err := shortestPath(context.Background(), g.adjacent, 1, 1, maxPathDepth)
+	if err != nil {
+		t.Fatalf("shortestPath: %v", err)
+	}
+	if !slices.Equal(got, []int64{1}) {
+		t.Errorf("path = %v, want [1]", got)
+	}
+	if g.calls != 0 {
+		t.Errorf("%d adjacency queries for from == to, want 0", g.calls)
+	}
+}
+
+func TestShortestPathTooWide(t *testing.T) {
+	edges := make([][2]int64, 0, maxPathVisited+1)
+	for i := int64(0); i <= maxPathVisited; i++ {
+		edges = append(edges, [2]int64{1, 100 + i})
+	}
+	g := newFriendGraph(append(edges, [2]int64{2, 3})...)
+
+	_, err := shortestPath(context.Background(), g.adjacent, 1, 2, maxPathDepth)
+	if !errors.Is(err, errPathSearchTooWide) {
+		t.Fatalf("err = %v, want errPathSearchTooWide", err)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		name              string
+		forward, backward map[int64]int64
+		meet              int64
+		want              []int64
+	}{
+		{
+			name:     "meet in the middle",
+			forward:  map[int64]int64{1: 1, 2: 1, 3: 2},
+			backward: map[int64]int64{5: 5, 4: 5, 3: 4},
+			meet:     3,
+			want:     []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:     "meet at from",
+			forward:  map[int64]int64{1: 1},
+			backward: map[int64]int64{3: 3, 2: 3, 1: 2},
+			meet:     1,
+			want:     []int64{1, 2, 3},
+		},
+		{
+			name:     "meet at to",
+			forward:  map[int64]int64{1: 1, 2: 1, 3: 2},
+			backward: map[int64]int64{3: 3},
+			meet:     3,
+			want:     []int64{1, 2, 3},
+		},
+		{
+			name:     "same user",
+			forward:  map[int64]int64{7: 7},
+			backward: map[int64]int64{7: 7},
+			meet:     7,
+			want:     []int64{7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinPath(tt.forward, tt.backward, tt.meet); !slices.Equal(got, tt.want) {
+				t.Errorf("path = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package graph
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"main/internal/auth/identity"
+	"main/internal/pg"
+)
+
+const (
+	defaultHops     = 2
+	maxHops         = 3
+	defaultMaxNodes = 300
+	maxMaxNodes     = 1000
+	maxPathDepth    = 6
+)
+
+// Node - пользователь в сети друзей с метриками
+type Node struct {
+	ID         int64   `json:"id"`
+	Username   string  `json:"username"`
+	Hop        int     `json:"hop"`
+	Degree     int     `json:"degree"`
+	Centrality float64 `json:"centrality"` // степень / (n - 1)
+	Cluster    int     `json:"cluster"`
+}
+
+type Link struct {
+	Source int64 `json:"source"`
+	Target int64 `json:"target"`
+}
+
+// Cluster - сообщество, найденное label propagation; кластеры пронумерованы
+// по убыванию размера
+type Cluster struct {
+	ID      int     `json:"id"`
+	Size    int     `json:"size"`
+	Members []int64 `json:"members"`
+}
+
+// queryInt reads an optional integer query parameter in [1, maxValue]
+func queryInt(c *gin.Context, name string, def, maxValue int) (int, bool) {
+	s := c.Query(name)
+	if s == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 1 || v > maxValue {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": name + " must be between 1 and " + strconv.Itoa(maxValue),
+		})
+		return 0, false
+	}
+	return v, true
+}
+
+// GetFriendNetworkHandler returns the accepted friendships around the session
+// user with clusters and degree centrality
+// GET /api/graph/friends?hops=2&max_nodes=300
+func GetFriendNetworkHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	hops, ok := queryInt(c, "hops", defaultHops, maxHops)
+	if !ok {
+		return
+	}
+	maxNodes, ok := queryInt(c, "max_nodes", defaultMaxNodes, maxMaxNodes)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	net, err := buildNetwork(ctx, viewerAdjacency(userID), userID, hops, maxNodes)
+	if err != nil {
+		zap.S().Errorw("Failed to build friend network", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build friend network"})
+		return
+	}
+
+	users, err := pg.GetFriendUsersByIDs(ctx, net.order)
+	if err != nil {
+		zap.S().Errorw("Failed to load friend network users", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not build friend network"})
+		return
+	}
+
+	labels := labelPropagation(net.order, net.adjacency)
+
+	byLabel := make(map[int64][]int64)
+	for _, id := range net.order {
+		byLabel[labels[id]] = append(byLabel[labels[id]], id)
+	}
+	clusters := make([]Cluster, 0, len(byLabel))
+	for _, members := range byLabel {
+		slices.Sort(members)
+		clusters = append(clusters, Cluster{Size: len(members), Members: members})
+	}
+	slices.SortFunc(clusters, func(a, b Cluster) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Members[0], b.Members[0]))
+	})
+	clusterOf := make(map[int64]int, len(net.order))
+	for i := range clusters {
+		clusters[i].ID = i
+		for _, id := range clusters[i].Members {
+			clusterOf[id] = i
+		}
+	}
+
+	nodes := make([]Node, 0, len(net.order))
+	links := make([]Link, 0)
+	for _, id := range net.order {
+		degree := len(net.adjacency[id])
+		centrality := 0.0
+		if len(net.order) > 1 {
+			centrality = float64(degree) / float64(len(net.order)-1)
+		}
+
+		nodes = append(nodes, Node{
+			ID:         id,
+			Username:   users[id].Username,
+			Hop:        net.hops[id],
+			Degree:     degree,
+			Centrality: centrality,
+			Cluster:    clusterOf[id],
+		})
+
+		for _, friendID := range net.adjacency[id] {
+			if id < friendID {
+				links = append(links, Link{Source: id, Target: friendID})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"center":    userID,
+		"hops":      hops,
+		"truncated": net.truncated,
+		"nodes":     nodes,
+		"links":     links,
+		"clusters":  clusters,
+	})
+}
+
+// GetFriendPathHandler answers "how are we connected": the shortest chain of
+// friends between two users, the session user by default.
+// from - только сам пользователь или его друг: иначе по ответу можно узнать,
+// дружат ли двое незнакомых. Пользователи, с которыми у вызывающего
+// блокировка, в путь не попадают.
+// GET /api/graph/friends/path?to=42&from=7&max_depth=6
+func GetFriendPathHandler(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	toID, err := strconv.ParseInt(c.Query("to"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+	fromID := userID
+	if s := c.Query("from"); s != "" {
+		fromID, err = strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+			return
+		}
+	}
+	maxDepth, ok := queryInt(c, "max_depth", maxPathDepth, maxPathDepth)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	if fromID != userID {
+		friends, err := pg.AreFriends(ctx, userID, fromID)
+		if err != nil {
+			zap.S().Errorw("Failed to check friendship", "error", err, "user_id", userID, "from_id", fromID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find connection"})
+			return
+		}
+		if !friends {
+			c.JSON(http.StatusForbidden, gin.H{"error": "from must be you or one of your friends"})
+			return
+		}
+	}
+
+	// Не ищем связь с теми, с кем есть блокировка в любую сторону
+	for _, otherID := range []int64{fromID, toID} {
+		if otherID == userID {
+			continue
+		}
+		blocked, err := pg.IsBlockedEither(ctx, userID, otherID)
+		if err != nil {
+			zap.S().Errorw("Failed to check block", "error", err, "user_id", userID, "other_id", otherID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find connection"})
+			return
+		}
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Connections of this user are not available"})
+			return
+		}
+	}
+
+	endpoints, err := pg.GetFriendUsersByIDs(ctx, []int64{fromID, toID})
+	if err != nil {
+		zap.S().Errorw("Failed to load users", "error", err, "from_id", fromID, "to_id", toID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find connection"})
+		return
+	}
+	if _, ok := endpoints[fromID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if _, ok := endpoints[toID]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	path, err := shortestPath(ctx, viewerAdjacency(userID), fromID, toID, maxDepth)
+	if errors.Is(err, errPathSearchTooWide) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No connection found within the search limit"})
+		return
+	}
+	if err != nil {
+		zap.S().Errorw("Failed to find friend path", "error", err, "from_id", fromID, "to_id", toID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find connection"})
+		return
+	}
+	if path == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No connection within " + strconv.Itoa(maxDepth) + " hops",
+		})
+		return
+	}
+
+	users, err := pg.GetFriendUsersByIDs(ctx, path)
+	if err != nil {
+		zap.S().Errorw("Failed to load path users", "error", err, "from_id", fromID, "to_id", toID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find connection"})
+		return
+	}
+
+	chain := make([]pg.FriendUser, 0, len(path))
+	for _, id := range path {
+		chain = append(chain, users[id])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":   fromID,
+		"to":     toID,
+		"length": len(path) - 1,
+		"path":   chain,
+	})
+}
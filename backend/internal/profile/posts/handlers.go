@@ -7,23 +7,103 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"main/internal/auth/identity"
+	"main/internal/media"
+	"main/internal/middleware"
 	"main/internal/models"
+	"main/internal/pagination"
 	"main/internal/pg"
 )
 
-var (
-	ErrPostNotFound = errors.New("post not found")
-	ErrAlreadyLiked = errors.New("already liked")
-	ErrNotLiked     = errors.New("not liked")
-)
+// checkWallAccess responds 403 if the owner of the wall has blocked the
+// session user.
+// Стена - публичная страница: анонимный зритель видит любую стену, в том
+// числе заблокированный пользователь, вышедший из аккаунта. Блокировка
+// отрезает аккаунт (чтение, комментарии, реакции), а не скрывает контент,
+// поэтому 403 говорит только о том, что стена скрыта от этого аккаунта.
+func checkWallAccess(c *gin.Context, wallUserID int64) bool {
+	viewerID := identity.UserID(c)
+	if viewerID == 0 || viewerID == wallUserID {
+		return true
+	}
+
+	blocked, err := pg.HasBlocked(c.Request.Context(), wallUserID, viewerID)
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to check wall access"},
+		)
+		return false
+	}
+	if blocked {
+		c.JSON(
+			http.StatusForbidden,
+			gin.H{"error": "this user's wall is hidden from your account"},
+		)
+		return false
+	}
+
+	return true
+}
+
+// loadWallPost resolves the post from the :postID route parameter and
+// responds 404 unless it is published on a user's wall, 403 if the wall
+// owner has blocked the caller
+func loadWallPost(c *gin.Context) (*models.Post, bool) {
+	postID, err := strconv.ParseInt(c.Param("postID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+		return nil, false
+	}
+
+	post, err := pg.GetPostByID(c.Request.Context(), postID)
+	if err != nil {
+		if errors.Is(err, pg.ErrPostNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+			return nil, false
+		}
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch post"},
+		)
+		return nil, false
+	}
+
+	if post.TargetType != models.PostTargetUser {
+		c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
+		return nil, false
+	}
+
+	if !checkWallAccess(c, post.WallUserID) {
+		return nil, false
+	}
+
+	return post, true
+}
+
+// ScopeWallPost resolves the wall post for routes nested under it (comments)
+// and stores it with middleware.SetPost. Пост не со стены - 404, стена
+// заблокировавшего пользователя - 403, как в обработчиках постов.
+func ScopeWallPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		post, ok := loadWallPost(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		middleware.SetPost(c, post)
+		c.Next()
+	}
+}
 
 // Handler handles HTTP requests for profile posts
 // CreatePost creates a new post
 // POST /api/profile/posts
 // Требует авторизацию (userID в контексте)
 func CreatePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -32,6 +112,8 @@ func CreatePost(c *gin.Context) {
 		Title  string `json:"title" binding:"required,max=255"`
 		Text   string `json:"text" binding:"required"`
 		PicURL string `json:"pic_url"`
+		// Загруженные через POST /api/media файлы, в порядке показа
+		MediaIDs []int64 `json:"media_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -39,15 +121,25 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
+	mediaIDs, ok := media.AttachmentIDs(c, req.MediaIDs)
+	if !ok {
+		return
+	}
+
 	post := &models.Post{
-		Title:       req.Title,
-		Text:        req.Text,
-		PicURL:      req.PicURL,
-		CommunityID: userID.(int64), // Для профиля - CommunityID = UserID
-		AuthorID:    userID.(int64),
+		Title:      req.Title,
+		Text:       req.Text,
+		PicURL:     req.PicURL,
+		TargetType: models.PostTargetUser,
+		WallUserID: userID,
+		AuthorID:   userID,
+		MediaIDs:   mediaIDs,
 	}
 
 	if err := pg.CreatePost(c.Request.Context(), post); err != nil {
+		if media.RespondAttachError(c, err) {
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to create post"},
@@ -62,7 +154,7 @@ func CreatePost(c *gin.Context) {
 }
 
 // GetUserPosts retrieves all posts for a user
-// GET /api/profile/:userID/posts?limit=20&offset=40
+// GET /user/:userID/posts?limit=20&cursor=...&total=1
 func GetUserPosts(c *gin.Context) {
 	userIDParam := c.Param("userID")
 	userID, err := strconv.ParseInt(userIDParam, 10, 64)
@@ -71,27 +163,19 @@ func GetUserPosts(c *gin.Context) {
 		return
 	}
 
-	limit := 10
-	offset := 0
-
-	if l := c.Query("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 &&
-			parsed <= 100 {
-			limit = parsed
-		}
+	if !checkWallAccess(c, userID) {
+		return
 	}
 
-	if o := c.Query("offset"); o != "" {
-		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+	opts, ok := pagination.FromQuery(c, 10)
+	if !ok {
+		return
 	}
 
-	posts, total, err := pg.GetUserPosts(
+	posts, info, err := pg.GetPosts(
 		c.Request.Context(),
-		userID,
-		limit,
-		offset,
+		models.UserWall(userID),
+		opts,
 	)
 	if err != nil {
 		c.JSON(
@@ -101,30 +185,38 @@ func GetUserPosts(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"posts":  posts,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	if err := pg.LoadPostStats(c.Request.Context(), posts, identity.UserID(c)); err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch posts"},
+		)
+		return
+	}
+
+	if err := pg.LoadPostMedia(c.Request.Context(), posts); err != nil {
+		c.JSON(
+			http.StatusInternalServerError,
+			gin.H{"error": "failed to fetch posts"},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, pagination.Response("posts", posts, opts, info))
 }
 
 // GetPost retrieves a single post
 // GET /api/profile/posts/:postID
 func GetPost(c *gin.Context) {
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	post, ok := loadWallPost(c)
+	if !ok {
 		return
 	}
 
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
+	err := pg.LoadPostStats(c.Request.Context(), []*models.Post{post}, identity.UserID(c))
+	if err == nil {
+		err = pg.LoadPostMedia(c.Request.Context(), []*models.Post{post})
+	}
 	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to fetch post"},
@@ -139,34 +231,19 @@ func GetPost(c *gin.Context) {
 // PUT /api/profile/posts/:postID
 // Требует авторизацию + проверку владельца
 func UpdatePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
-		return
-	}
-
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
-	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
-		)
+	post, ok := loadWallPost(c)
+	if !ok {
 		return
 	}
 
 	// Проверяем владельца поста
-	if post.AuthorID != userID.(int64) {
+	if post.AuthorID != userID {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only edit your own posts"},
@@ -214,34 +291,19 @@ func UpdatePost(c *gin.Context) {
 // DELETE /api/profile/posts/:postID
 // Требует авторизацию + проверку владельца
 func DeletePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
-		return
-	}
-
-	post, err := pg.GetPostByID(c.Request.Context(), postID)
-	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
-			return
-		}
-		c.JSON(
-			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
-		)
+	post, ok := loadWallPost(c)
+	if !ok {
 		return
 	}
 
 	// Проверяем владельца
-	if post.AuthorID != userID.(int64) {
+	if post.AuthorID != userID {
 		c.JSON(
 			http.StatusForbidden,
 			gin.H{"error": "you can only delete your own posts"},
@@ -249,7 +311,7 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	if err := pg.DeletePost(c.Request.Context(), postID); err != nil {
+	if err := pg.DeletePost(c.Request.Context(), post.ID); err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
 			gin.H{"error": "failed to delete post"},
@@ -260,86 +322,132 @@ func DeletePost(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "post deleted successfully"})
 }
 
-// LikePost adds a like to a post
-// POST /api/profile/posts/:postID/like
+// LikePost puts the "like" reaction on a post
+// POST /api/user/posts/:postID/like
 // Требует авторизацию
 func LikePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	setReaction(c, models.ReactionLike)
+}
+
+// SetReaction sets or changes the caller's reaction on a post
+// PUT /api/user/posts/:postID/reaction
+// Body: {"reaction": "love"}
+// Требует авторизацию
+func SetReaction(c *gin.Context) {
+	var req struct {
+		Reaction string `json:"reaction" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !models.IsValidReaction(req.Reaction) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
+		return
+	}
+
+	setReaction(c, req.Reaction)
+}
+
+func setReaction(c *gin.Context, reaction string) {
+	userID := identity.UserID(c)
+	if userID == 0 {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	post, ok := loadWallPost(c)
+	if !ok {
 		return
 	}
 
-	// Проверяем существует ли пост
-	_, err = pg.GetPostByID(c.Request.Context(), postID)
+	err := pg.SetPostReaction(c.Request.Context(), post.ID, userID, reaction)
 	if err != nil {
-		if errors.Is(err, ErrPostNotFound) {
+		if errors.Is(err, pg.ErrPostNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "post not found"})
 			return
 		}
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to fetch post"},
+			gin.H{"error": "failed to react to post"},
 		)
 		return
 	}
 
-	if err := pg.LikePost(c.Request.Context(), postID, userID.(int64)); err != nil {
-		if errors.Is(err, ErrAlreadyLiked) {
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "reaction saved successfully",
+		"reaction": reaction,
+	})
+}
+
+// RemoveReaction removes the caller's reaction from a post
+// DELETE /api/user/posts/:postID/reaction (и /like)
+// Требует авторизацию
+func RemoveReaction(c *gin.Context) {
+	userID := identity.UserID(c)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	post, ok := loadWallPost(c)
+	if !ok {
+		return
+	}
+
+	if err := pg.RemovePostReaction(c.Request.Context(), post.ID, userID); err != nil {
+		if errors.Is(err, pg.ErrNoReaction) {
 			c.JSON(
-				http.StatusConflict,
-				gin.H{"error": "you already liked this post"},
+				http.StatusNotFound,
+				gin.H{"error": "you haven't reacted to this post"},
 			)
 			return
 		}
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to like post"},
+			gin.H{"error": "failed to remove reaction"},
 		)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "post liked successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "reaction removed successfully"})
 }
 
-// UnlikePost removes a like from a post
-// DELETE /api/profile/posts/:postID/like
-// Требует авторизацию
-func UnlikePost(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+// GetReactions lists who reacted to a post, newest first
+// GET /user/posts/:postID/reactions?reaction=love&limit=50&cursor=...
+func GetReactions(c *gin.Context) {
+	post, ok := loadWallPost(c)
+	if !ok {
 		return
 	}
 
-	postIDParam := c.Param("postID")
-	postID, err := strconv.ParseInt(postIDParam, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post ID"})
+	kind := c.Query("reaction")
+	if kind != "" && !models.IsValidReaction(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "unsupported reaction",
+			"allowed": models.ReactionKinds,
+		})
 		return
 	}
 
-	if err := pg.UnlikePost(c.Request.Context(), postID, userID.(int64)); err != nil {
-		if errors.Is(err, ErrNotLiked) {
-			c.JSON(
-				http.StatusNotFound,
-				gin.H{"error": "you haven't liked this post"},
-			)
-			return
-		}
+	opts, ok := pagination.FromQuery(c, 50)
+	if !ok {
+		return
+	}
+
+	reactions, info, err := pg.GetPostReactions(c.Request.Context(), post.ID, kind, opts)
+	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError,
-			gin.H{"error": "failed to unlike post"},
+			gin.H{"error": "failed to fetch reactions"},
 		)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "post unliked successfully"})
+	c.JSON(http.StatusOK, pagination.Response("reactions", reactions, opts, info))
 }
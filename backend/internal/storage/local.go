@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores objects as files under Root
+type Local struct {
+	Root string
+}
+
+// NewLocal creates the root directory if needed
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+	return &Local{Root: root}, nil
+}
+
+// path maps a key to a file under Root, rejecting keys that escape it
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, `\`) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes to a temporary file and renames it, so readers never see a
+// partially written object
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+func (l *Local) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		name, err := l.path(key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
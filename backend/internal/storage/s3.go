@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// S3 stores objects in an S3-compatible bucket (AWS S3, MinIO, Ceph RGW...).
+// Запросы подписываются AWS Signature V4, адресация path-style
+// (<endpoint>/<bucket>/<key>), которую понимают все совместимые сервера.
+type S3 struct {
+	Endpoint  string // например "http://localhost:9000"
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3 validates the configuration; the bucket must already exist
+func NewS3(endpoint, bucket, region, accessKey, secretKey string) (*S3, error) {
+	if endpoint == "" || bucket == "" || accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("s3 storage requires endpoint, bucket and credentials")
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Put buffers the object to compute the payload hash the signature covers
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("store", key, resp)
+	}
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", key, err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error("open", key, resp)
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := s.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		resp.Body.Close()
+
+		// S3 отвечает 204 и на удаление несуществующего ключа
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+			resp.StatusCode != http.StatusNotFound {
+			return s3Error("delete", key, resp)
+		}
+	}
+	return nil
+}
+
+func s3Error(op, key string, resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("failed to %s %s: s3 responded %s: %s", op, key, resp.Status, bytes.TrimSpace(detail))
+}
+
+// escapePath URI-encodes every byte except unreserved characters and '/',
+// as the canonical request of Signature V4 requires
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	path := "/" + s.Bucket + "/" + strings.TrimLeft(key, "/")
+	escaped := escapePath(path)
+
+	req, err := http.NewRequestWithContext(ctx, method, s.Endpoint+escaped, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build s3 request: %w", err)
+	}
+	req.ContentLength = int64(len(body))
+
+	s.sign(req, escaped, body, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds the Signature V4 headers. Подписываются host и все заголовки,
+// уже выставленные на запросе.
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html
+func (s *S3) sign(req *http.Request, canonicalURI string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payload)
+
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	slices.Sort(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"", // query string
+		headers.String(),
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(s.SecretKey, date, s.Region, "s3"), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// signingKey derives the Signature V4 key for one day, region and service
+func signingKey(secretKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
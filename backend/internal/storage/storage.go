@@ -0,0 +1,22 @@
+// Package storage keeps uploaded files (avatars, media) behind a small
+// interface so the backing store can be swapped without touching handlers.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Storage stores opaque objects under slash-separated keys,
+// например "avatars/42/1712345678/128.jpg"
+type Storage interface {
+	// Put writes the object, replacing an existing one with the same key
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Open returns the object contents or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes objects; missing keys are not an error
+	Delete(ctx context.Context, keys ...string) error
+}